@@ -0,0 +1,198 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcontour/ir2proxy/internal/inplace"
+	"github.com/projectcontour/ir2proxy/internal/input"
+)
+
+// rewriteInPlace replaces the objects in the input files with their
+// translations. Only the documents with translated objects in them are
+// changed, the rest of each file is kept byte for byte.
+// A file isn't changed at all if any of the objects in it failed.
+func (r *report) rewriteInPlace() error {
+
+	log := logrus.StandardLogger()
+
+	var files []string
+	byFile := make(map[string][]*result)
+	for _, res := range r.results {
+		if res.source == nil {
+			continue
+		}
+		if _, ok := byFile[res.source.file]; !ok {
+			files = append(files, res.source.file)
+		}
+		byFile[res.source.file] = append(byFile[res.source.file], res)
+	}
+
+	var failed []string
+	for _, file := range files {
+		if err := rewriteFile(file, byFile[file]); err != nil {
+			log.WithField("file", file).Error(err)
+			failed = append(failed, file)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("these files weren't rewritten: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// rewriteFile replaces the objects in file with the translations in results.
+func rewriteFile(file string, results []*result) error {
+
+	byLine := make(map[int][]*result)
+	for _, res := range results {
+		if res.failed() {
+			return fmt.Errorf("can't rewrite the file, since %s couldn't be translated", res.describe())
+		}
+		if res.passthrough {
+			continue
+		}
+		byLine[res.source.line] = append(byLine[res.source.line], res)
+	}
+	if len(byLine) == 0 {
+		return nil
+	}
+	// The translations are spliced in as YAML, which would leave a JSON
+	// file half YAML.
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		return fmt.Errorf("can't rewrite a JSON file in place")
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	var rewritten []*result
+	// matched counts the documents with translated objects in them.
+	matched := 0
+	end := 0
+	documents := input.NewDocumentReader(bytes.NewReader(data))
+	for {
+		doc, err := documents.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		docResults, ok := byLine[doc.Line]
+		if !ok {
+			continue
+		}
+		if err := checkListKind(doc.Data); err != nil {
+			return fmt.Errorf("can't rewrite the document at line %d in place, %s", doc.Line, err)
+		}
+
+		var replacements []inplace.Replacement
+		for _, res := range docResults {
+			if len(res.objects) != 1 {
+				return fmt.Errorf("can't rewrite %s in place, it was translated to %d objects", res.describe(), len(res.objects))
+			}
+			object, err := marshalObject(res.objects[0])
+			if err != nil {
+				return err
+			}
+			replacements = append(replacements, inplace.Replacement{
+				Item:    res.source.item,
+				YAML:    object,
+				Comment: commentedWarnings(res.warnings),
+			})
+		}
+		replaced, err := inplace.Replace(doc.Data, replacements)
+		if err != nil {
+			return fmt.Errorf("can't rewrite the document at line %d in place, %s", doc.Line, err)
+		}
+
+		out = append(out, data[end:doc.Offset]...)
+		out = append(out, replaced...)
+		end = doc.Offset + len(doc.Data)
+		rewritten = append(rewritten, docResults...)
+		matched++
+	}
+	out = append(out, data[end:]...)
+
+	if matched != len(byLine) {
+		return fmt.Errorf("can't rewrite the file, it's changed since it was read")
+	}
+	if err := replaceFile(file, out, info.Mode()); err != nil {
+		return err
+	}
+	for _, res := range rewritten {
+		res.files = []string{file}
+	}
+	return nil
+}
+
+// replaceFile replaces the contents of file with data. The data is written to
+// a temporary file in the same directory, which is renamed over file, so that
+// file is never left half written.
+func replaceFile(file string, data []byte, mode os.FileMode) error {
+
+	temp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	// Once it's been renamed, there's nothing left to remove.
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(mode); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
+
+// checkListKind returns an error if a document is a typed List, like an
+// IngressRouteList, which can't hold the translated objects.
+func checkListKind(data []byte) error {
+	var meta struct {
+		Kind string `json:"kind"`
+	}
+	// Arrays don't have a kind, and aren't Lists.
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil
+	}
+	if meta.Kind != "List" && strings.HasSuffix(meta.Kind, "List") {
+		return fmt.Errorf("%s items can't be replaced, only the items of a v1 List", meta.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReplaceFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ir2proxy-inplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "input.yaml")
+	if err := ioutil.WriteFile(file, []byte("kind: IngressRoute\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceFile(file, []byte("kind: HTTPProxy\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(data), "kind: HTTPProxy\n"); diff != "" {
+		t.Errorf("contents mismatch:\n%v", diff)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0600 {
+		t.Errorf("expected mode %v, got %v", os.FileMode(0600), info.Mode())
+	}
+	// The temporary file has been renamed, so it's the only file left.
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if diff := cmp.Diff(names, []string{"input.yaml"}); diff != "" {
+		t.Errorf("files mismatch:\n%v", diff)
+	}
+}
+
+func TestRewriteFileRefusesJSON(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ir2proxy-inplace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := `{"apiVersion": "contour.heptio.com/v1beta1", "kind": "IngressRoute", "metadata": {"name": "blog"}}`
+	file := filepath.Join(dir, "input.JSON")
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []*result{{
+		source:  &source{file: file, line: 1, item: -1},
+		objects: []interface{}{map[string]string{"kind": "HTTPProxy"}},
+	}}
+	if err := rewriteFile(file, results); err == nil {
+		t.Fatal("expected an error rewriting a JSON file")
+	}
+
+	got, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(got), data); diff != "" {
+		t.Errorf("the file was changed:\n%v", diff)
+	}
+}
@@ -15,22 +15,33 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/ghodss/yaml"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/projectcontour/ir2proxy/internal/audit"
+	"github.com/projectcontour/ir2proxy/internal/delegation"
+	"github.com/projectcontour/ir2proxy/internal/gatewayapi"
+	"github.com/projectcontour/ir2proxy/internal/input"
 	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
 	"github.com/projectcontour/ir2proxy/internal/translator"
 	"github.com/projectcontour/ir2proxy/internal/validate"
+	"github.com/projectcontour/ir2proxy/internal/warning"
 	"github.com/sirupsen/logrus"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 var build = "devel"
 
+const stdinPlaceholder = "<stdin>"
+
 func main() {
 
 	exitcode := run()
@@ -43,73 +54,457 @@ func run() int {
 	log := logrus.StandardLogger()
 	app := kingpin.New("ir2proxy", "Contour IngressRoute to HTTPProxy conversion tool.")
 	app.Version(build)
-	yamlfile := app.Arg("yaml", "YAML file to parse for IngressRoute objects").Required().ExistingFile()
+	recursive := app.Flag("recursive", "Search directories recursively").Short('R').Bool()
+
+	translateCmd := app.Command("translate", "Translate IngressRoutes to HTTPProxies. This is the default command.").Default()
+	yamlfiles := translateCmd.Arg("yaml", "YAML files, directories or glob patterns to parse for IngressRoute objects. Use - or leave empty to read from stdin.").Strings()
+	passthrough := translateCmd.Flag("passthrough", "Output objects that can't be translated unchanged, instead of failing").Bool()
+	ingress := translateCmd.Flag("ingress", "Translate Ingress objects to HTTPProxies as well, instead of treating them like other objects that can't be translated").Bool()
+	target := translateCmd.Flag("target", "The API to translate IngressRoutes to, httpproxy or gatewayapi").Default(targetHTTPProxy).Enum(targetHTTPProxy, targetGatewayAPI)
+	gateway := translateCmd.Flag("gateway", "The namespace/name of the Gateway that routes attach to, for --target=gatewayapi").Default("projectcontour/contour").String()
+	reverse := translateCmd.Flag("reverse", "Translate HTTPProxy objects back to IngressRoutes, to roll back a migration").Bool()
+	ignore := translateCmd.Flag("ignore", "Warning codes to ignore, separated by commas. Can be repeated.").Strings()
+	severities := translateCmd.Flag("severity", "Override the severity of a warning code, like IR2P-LB-CONFLICT=error. Can be repeated.").Strings()
+	warningsAsErrors := translateCmd.Flag("warnings-as-errors", "Treat warnings as errors, so that objects with warnings aren't output").Bool()
+	outputDir := translateCmd.Flag("output-dir", "Write the output objects to files under this directory, instead of stdout").String()
+	layout := translateCmd.Flag("layout", "The layout of the files written by --output-dir: object writes each object to <namespace>/<name>.<kind>.yaml, mirror writes the objects from each input file to the same path").Default(layoutObject).Enum(layoutObject, layoutMirror)
+	force := translateCmd.Flag("force", "Overwrite files that already exist under --output-dir").Bool()
+	inPlace := translateCmd.Flag("in-place", "Replace the IngressRoutes and TLSCertificateDelegations in the input files with their translations, keeping the rest of the files unchanged").Bool()
+	outputFormat := translateCmd.Flag("output", "The format to output, yaml or json. json outputs a single document with the translated objects, their warnings and errors, and a summary.").Short('o').Default(formatYAML).Enum(formatYAML, formatJSON)
 
+	simulateCmd := app.Command("simulate", "Compare where requests are routed by IngressRoutes and the HTTPProxies they're translated to.")
+	simulateFiles := simulateCmd.Arg("yaml", "YAML files, directories or glob patterns to parse for IngressRoute and HTTPProxy objects. Use - or leave empty to read from stdin.").Strings()
+	requests := simulateCmd.Flag("request", "A request to resolve, like 'GET https://foo.bar.com/api'. Can be repeated. Defaults to a request for each route.").Short('r').Strings()
+
+	// kingpin won't accept a bare "-" as an argument, so swap it for a
+	// placeholder while parsing.
 	args := os.Args[1:]
-	kingpin.MustParse(app.Parse(args))
+	for index, arg := range args {
+		if arg == input.Stdin {
+			args[index] = stdinPlaceholder
+		}
+	}
+	command := kingpin.MustParse(app.Parse(args))
+	restoreStdin(*yamlfiles)
+	restoreStdin(*simulateFiles)
+
+	if command == simulateCmd.FullCommand() {
+		if len(*simulateFiles) == 0 && isTerminal(os.Stdin) {
+			app.Usage(args)
+			return 1
+		}
+		files, err := input.Files(*simulateFiles, *recursive)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+		return runSimulate(files, *requests)
+	}
 
-	data, err := ioutil.ReadFile(*yamlfile)
+	gatewayName, err := parseGateway(*gateway)
 	if err != nil {
 		log.Error(err)
+		return 1
 	}
 
-	for _, yamldoc := range splitYAML(data) {
-		ir, err := k8sdecoder.DecodeIngressRoute(yamldoc)
-		if err != nil {
-			log.Error(err)
+	policy, err := parsePolicy(*ignore, *severities, *warningsAsErrors)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	if *reverse && *target != targetHTTPProxy {
+		log.Errorf("--reverse translates HTTPProxy objects, it can't be used with --target=%s", *target)
+		return 1
+	}
+
+	if *inPlace {
+		switch {
+		case *reverse:
+			log.Error("--in-place can't be used with --reverse")
+			return 1
+		case *target != targetHTTPProxy:
+			log.Errorf("--in-place replaces IngressRoutes with HTTPProxies, it can't be used with --target=%s", *target)
+			return 1
+		case *outputDir != "":
+			log.Error("--in-place rewrites the input files, it can't be used with --output-dir")
+			return 1
+		case *ingress:
+			log.Error("--in-place only replaces contour.heptio.com objects, it can't be used with --ingress")
 			return 1
 		}
+		// The objects that aren't rewritten are left in the files.
+		*passthrough = true
+	}
 
-		validationErrors := validate.CheckIngressRoute(ir)
-		if len(validationErrors) > 0 {
-			for _, validationError := range validationErrors {
-				log.Error(validationError)
-			}
+	if len(*yamlfiles) == 0 && isTerminal(os.Stdin) {
+		app.Usage(args)
+		return 1
+	}
+
+	files, err := input.Files(*yamlfiles, *recursive)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	for _, file := range files {
+		if *inPlace && file == input.Stdin {
+			log.Error("--in-place can't be used with stdin")
 			return 1
 		}
+	}
 
-		hp, warnings, err := translator.IngressRouteToHTTPProxy(ir)
+	out := outputOptions{format: *outputFormat, dir: *outputDir, layout: *layout, force: *force, inPlace: *inPlace}
+	if *reverse {
+		return runReverse(files, *passthrough, policy, out)
+	}
+
+	// Errors for individual objects are logged and recorded in the report,
+	// and the rest of the objects are still translated.
+	var rep report
+
+	// Decode all the IngressRoutes first, so that delegation can be
+	// followed across all the files.
+	var irs []*irv1beta1.IngressRoute
+	var irResults []*result
+	var tcds []*irv1beta1.TLSCertificateDelegation
+	for _, file := range files {
+		if err := readFile(file, func(doc *input.Document) {
+			docSource := source{file: file, line: doc.Line, item: -1}
+			items, err := k8sdecoder.Decode(doc.Data)
+			if err != nil {
+				rep.add(&docSource).fail(log.WithFields(docSource.fields()), err)
+				return
+			}
+
+			for _, item := range items {
+				itemSource := source{file: file, line: doc.Line, item: item.Index}
+				itemLog := log.WithFields(itemSource.fields())
+				res := rep.add(&itemSource)
+				if tcd, ok := item.Object.(*irv1beta1.TLSCertificateDelegation); ok {
+					res.object = &warning.Object{Kind: "TLSCertificateDelegation", Namespace: tcd.Namespace, Name: tcd.Name}
+					ignoreAnnotated(itemLog, &policy, *res.object, tcd.Annotations)
+					tcdv1, warnings := translator.TLSCertificateDelegationToV1(tcd)
+					res.warn(itemLog, policy.Apply(warnings))
+					tcds = append(tcds, tcd)
+					res.objects = []interface{}{tcdv1}
+					continue
+				}
+
+				if ing, ok := item.Object.(*netv1beta1.Ingress); ok && *ingress {
+					ingressLog := itemLog.WithField("ingress", ing.Namespace+"/"+ing.Name)
+					res.object = &warning.Object{Kind: "Ingress", Namespace: ing.Namespace, Name: ing.Name}
+					ignoreAnnotated(ingressLog, &policy, *res.object, ing.Annotations)
+					proxies, warnings, err := translator.IngressToHTTPProxies(ing)
+					if err != nil {
+						res.fail(ingressLog, err)
+						continue
+					}
+					res.warn(ingressLog, policy.Apply(warnings))
+					for _, hp := range proxies {
+						res.objects = append(res.objects, hp)
+					}
+					continue
+				}
+
+				ir, ok := item.Object.(*irv1beta1.IngressRoute)
+				if !ok && *passthrough {
+					passthroughResult(res, itemLog, doc, item)
+					continue
+				}
+				if !ok {
+					kinds := "IngressRoute and TLSCertificateDelegation"
+					if *ingress {
+						kinds = "IngressRoute, TLSCertificateDelegation and Ingress"
+					}
+					res.fail(itemLog, fmt.Errorf("can only parse %s, a %s was supplied", kinds, item.GroupVersionKind))
+					continue
+				}
+
+				res.object = &warning.Object{Kind: "IngressRoute", Namespace: ir.Namespace, Name: ir.Name}
+				// Objects that fail validation can't be translated, so
+				// the errors can't be ignored.
+				if validationErrors := validate.CheckIngressRoute(ir); len(validationErrors) > 0 {
+					res.warn(itemLog, validationErrors)
+					continue
+				}
+
+				ignoreAnnotated(itemLog, &policy, *res.object, ir.Annotations)
+				irs = append(irs, ir)
+				irResults = append(irResults, res)
+			}
+		}); err != nil {
+			rep.fail(log.WithField("file", file), err)
+		}
+	}
+
+	// Gateway API has ReferenceGrants for secrets in other namespaces instead,
+	// which are generated. The warnings are recorded against each
+	// IngressRoute, so that one with an error isn't output.
+	if *target == targetHTTPProxy {
+		for index, ir := range irs {
+			res := irResults[index]
+			objectLog := log.WithFields(res.source.fields()).WithField("ingressroute", delegation.KeyOf(ir))
+			res.warn(objectLog, policy.Apply(validate.CheckTLSCertificateDelegations([]*irv1beta1.IngressRoute{ir}, tcds)))
+		}
+	}
+
+	// The warnings for delegation findings are logged once, and added to the
+	// output of the IngressRoute they're about.
+	findings := make(map[delegation.Key][]warning.Warning)
+	for _, finding := range delegation.Analyze(delegation.Build(irs)) {
+		for _, w := range policy.Apply([]warning.Warning{finding.Warning()}) {
+			logWarning(log.WithField("kind", finding.Kind), w)
+			findings[finding.Key] = append(findings[finding.Key], w)
+		}
+	}
+
+	var shared []interface{}
+	var translations []translation
+	switch *target {
+	case targetGatewayAPI:
+		var gatewayTranslations []gatewayapi.Translation
+		shared, gatewayTranslations = gatewayapi.IngressRoutesToGatewayAPI(irs, gatewayName)
+		for _, t := range gatewayTranslations {
+			translations = append(translations, translation{ingressRoute: t.IngressRoute, objects: t.Objects, warnings: t.Warnings, err: t.Err})
+		}
+	default:
+		for _, t := range translator.IngressRoutesToHTTPProxies(irs) {
+			translations = append(translations, translation{ingressRoute: t.IngressRoute, objects: []interface{}{t.HTTPProxy}, warnings: t.Warnings, err: t.Err})
+		}
+	}
+
+	for index, translation := range translations {
+		ir, res := translation.ingressRoute, irResults[index]
+		objectLog := log.WithFields(res.source.fields()).WithField("ingressroute", delegation.KeyOf(ir))
+		if translation.err != nil {
+			res.fail(objectLog, translation.err)
+			continue
+		}
+		res.warn(objectLog, policy.Apply(translation.warnings))
+
+		droppedFields, err := audit.CheckIngressRoute(ir)
 		if err != nil {
-			log.Error(err)
-			return 1
+			res.fail(objectLog, err)
+			continue
 		}
-		for _, warning := range warnings {
-			log.Warn(warning)
+		var droppedWarnings []warning.Warning
+		for _, droppedField := range droppedFields {
+			droppedWarnings = append(droppedWarnings, droppedField.Warning())
 		}
+		res.warn(objectLog, policy.Apply(warning.WithObject(droppedWarnings, *res.object)))
+		res.warnings = append(res.warnings, findings[delegation.KeyOf(ir)]...)
+
+		res.objects = translation.objects
+	}
 
-		outputYAML, err := yaml.Marshal(hp)
+	// Objects shared by all the translations, like a Gateway, come first.
+	if len(shared) > 0 {
+		rep.results = append([]*result{{objects: shared}}, rep.results...)
+	}
+
+	return finish(&rep, out)
+}
+
+// The APIs that IngressRoutes can be translated to.
+const (
+	targetHTTPProxy  = "httpproxy"
+	targetGatewayAPI = "gatewayapi"
+)
+
+// translation is the translation of an IngressRoute to any target.
+type translation struct {
+	ingressRoute *irv1beta1.IngressRoute
+	objects      []interface{}
+	warnings     []warning.Warning
+	err          error
+}
+
+// restoreStdin swaps the stdin placeholder back to "-" in a list of files.
+func restoreStdin(files []string) {
+	for index, file := range files {
+		if file == stdinPlaceholder {
+			files[index] = input.Stdin
+		}
+	}
+}
+
+// parseGateway parses the namespace/name of a Gateway.
+func parseGateway(gateway string) (types.NamespacedName, error) {
+	parts := strings.Split(gateway, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid --gateway %q, must be namespace/name", gateway)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// parsePolicy returns the warning policy for the --ignore, --severity and
+// --warnings-as-errors flags.
+func parsePolicy(ignore []string, severities []string, warningsAsErrors bool) (warning.Policy, error) {
+	policy := warning.Policy{
+		Ignore:           make(map[warning.Code]bool),
+		Severities:       make(map[warning.Code]warning.Severity),
+		WarningsAsErrors: warningsAsErrors,
+	}
+	for _, value := range ignore {
+		codes, err := warning.ParseCodes(value)
 		if err != nil {
-			log.Warn(err)
-			return 1
+			return warning.Policy{}, fmt.Errorf("invalid --ignore %q, %s", value, err)
+		}
+		for _, code := range codes {
+			policy.Ignore[code] = true
+		}
+	}
+	for _, value := range severities {
+		code, severity, err := warning.ParseSeverity(value)
+		if err != nil {
+			return warning.Policy{}, fmt.Errorf("invalid --severity, %s", err)
 		}
-		// The Kubernetes standard header field `currentTimestamp` serializes weirdly,
-		// so filter it out.
-		// See https://github.com/projectcontour/ir2proxy/issues/8 for more explanation here.
-		outputYAML = bytes.ReplaceAll(outputYAML, []byte("  creationTimestamp: null\n"), []byte(""))
-		outputWarnings := commentedWarnings(warnings)
-		fmt.Printf("---\n%s\n%s", outputWarnings, outputYAML)
+		policy.Severities[code] = severity
 	}
+	return policy, nil
+}
 
-	return 0
+// ignoreAnnotated adds the codes ignored by the annotations of an object to
+// the policy, and logs any that are invalid.
+func ignoreAnnotated(log *logrus.Entry, policy *warning.Policy, object warning.Object, annotations map[string]string) {
+	if err := policy.IgnoreAnnotated(object, annotations); err != nil {
+		log.Warn(err)
+	}
 }
 
-func commentedWarnings(warnings []string) string {
-	for index, warning := range warnings {
-		warnings[index] = "# " + strings.ReplaceAll(warning, ". ", ".\n# ")
+// render returns the YAML document for a translated object, with its
+// warnings as comments.
+func render(obj interface{}, warnings []warning.Warning) ([]byte, error) {
+	outputYAML, err := marshalObject(obj)
+	if err != nil {
+		return nil, err
 	}
-	return strings.Join(warnings, "\n")
+	outputWarnings := commentedWarnings(warnings)
+	return []byte(fmt.Sprintf("---\n%s\n%s", outputWarnings, outputYAML)), nil
+}
 
+// marshalObject returns the YAML for a translated object.
+func marshalObject(obj interface{}) ([]byte, error) {
+	outputYAML, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	// The Kubernetes standard header field `currentTimestamp` serializes weirdly,
+	// so filter it out.
+	// See https://github.com/projectcontour/ir2proxy/issues/8 for more explanation here.
+	return bytes.ReplaceAll(outputYAML, []byte("  creationTimestamp: null\n"), []byte("")), nil
 }
 
-func splitYAML(yamldata []byte) [][]byte {
+// passthroughResult records an object that's passed through unchanged.
+func passthroughResult(res *result, log *logrus.Entry, doc *input.Document, item k8sdecoder.Item) {
+	data, err := passthroughData(doc, item)
+	if err != nil {
+		res.fail(log, err)
+		return
+	}
+	res.passthrough = true
+	res.document = data
+	res.objects = []interface{}{json.RawMessage(item.Raw)}
+}
 
-	var yamldocs [][]byte
-	for _, yamldoc := range bytes.Split(yamldata, []byte("---")) {
-		if len(yamldoc) == 0 {
-			continue
+// passthroughData returns the YAML document to output for an object that's
+// passed through. Objects that had a document to themselves are output exactly
+// as they were read, objects from a List are output on their own.
+func passthroughData(doc *input.Document, item k8sdecoder.Item) ([]byte, error) {
+
+	data := doc.Data
+	if item.Index >= 0 {
+		var err error
+		data, err = yaml.JSONToYAML(item.Raw)
+		if err != nil {
+			return nil, err
 		}
-		yamldocs = append(yamldocs, yamldoc)
 	}
-	return yamldocs
 
+	// A document read after a '---' starts with the rest of that line.
+	if len(data) == 0 || !bytes.ContainsAny(data[:1], " \t\r\n") {
+		data = append([]byte("\n"), data...)
+	}
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	return append([]byte("---"), data...), nil
+}
+
+// source records where an object was read from.
+type source struct {
+	file string
+	// line is the line the object's document starts on.
+	line int
+	// item is the position of the object in the List it came from, or -1.
+	item int
+}
+
+func (s source) fields() logrus.Fields {
+	fields := logrus.Fields{"file": s.file, "line": s.line}
+	if s.item >= 0 {
+		fields["item"] = s.item
+	}
+	return fields
+}
+
+// isTerminal returns true if file is a terminal, rather than a pipe or a file.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// commentedWarnings returns the warnings as YAML comments, each starting
+// with its code.
+func commentedWarnings(warnings []warning.Warning) string {
+	comments := make([]string, len(warnings))
+	for index, w := range warnings {
+		comments[index] = fmt.Sprintf("# %s: %s", w.Code, strings.ReplaceAll(w.String(), ". ", ".\n# "))
+	}
+	return strings.Join(comments, "\n")
+
+}
+
+// logWarning logs a warning at the level of its severity, with its code
+// and path as fields.
+func logWarning(log *logrus.Entry, w warning.Warning) {
+	fields := logrus.Fields{"code": w.Code}
+	if w.Path != "" {
+		fields["path"] = w.Path
+	}
+	switch w.Severity {
+	case warning.SeverityError:
+		log.WithFields(fields).Error(w)
+	case warning.SeverityInfo:
+		log.WithFields(fields).Info(w)
+	default:
+		log.WithFields(fields).Warn(w)
+	}
+}
+
+// readFile calls handle for each YAML document in file, and returns an
+// error if the file couldn't be read.
+func readFile(file string, handle func(*input.Document)) error {
+
+	reader, err := input.Open(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	documents := input.NewDocumentReader(reader)
+	for {
+		doc, err := documents.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		handle(doc)
+	}
 }
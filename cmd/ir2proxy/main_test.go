@@ -0,0 +1,279 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcontour/ir2proxy/internal/input"
+)
+
+const secretIngressRoutes = `apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: tls
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.example.com
+    tls:
+      secretName: certs/foo
+  routes:
+  - match: /
+    services:
+    - name: s1
+      port: 80
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: plain
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: bar.example.com
+  routes:
+  - match: /
+    services:
+    - name: s2
+      port: 80
+`
+
+const secretDelegation = `---
+apiVersion: contour.heptio.com/v1beta1
+kind: TLSCertificateDelegation
+metadata:
+  name: foo
+  namespace: certs
+spec:
+  delegations:
+  - secretName: foo
+    targetNamespaces:
+    - default
+`
+
+func TestRunSecretNotDelegated(t *testing.T) {
+
+	tests := map[string]struct {
+		input       string
+		args        []string
+		wantExit    int
+		wantObjects []string
+		wantWarning bool
+	}{
+		"warning on the HTTPProxy": {
+			input:       secretIngressRoutes,
+			wantObjects: []string{"HTTPProxy default/tls", "HTTPProxy default/plain"},
+			wantWarning: true,
+		},
+		"delegated": {
+			input:       secretIngressRoutes + secretDelegation,
+			wantObjects: []string{"HTTPProxy default/tls", "HTTPProxy default/plain", "TLSCertificateDelegation certs/foo"},
+		},
+		"ignored": {
+			input:       secretIngressRoutes,
+			args:        []string{"--ignore", "IR2P-SECRET-NOT-DELEGATED"},
+			wantObjects: []string{"HTTPProxy default/tls", "HTTPProxy default/plain"},
+		},
+		"an error fails only that object": {
+			input:       secretIngressRoutes,
+			args:        []string{"--severity", "IR2P-SECRET-NOT-DELEGATED=error"},
+			wantExit:    1,
+			wantObjects: []string{"HTTPProxy default/plain"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			exitcode, output := runTranslate(t, tc.input, tc.args...)
+			if exitcode != tc.wantExit {
+				t.Errorf("expected exit code %d, got %d", tc.wantExit, exitcode)
+			}
+			if diff := cmp.Diff(outputObjects(t, output), tc.wantObjects); diff != "" {
+				t.Errorf("output objects mismatch:\n%v", diff)
+			}
+			if got := strings.Contains(output, "# IR2P-SECRET-NOT-DELEGATED:"); got != tc.wantWarning {
+				t.Errorf("expected warning comment %t, got %t in:\n%s", tc.wantWarning, got, output)
+			}
+		})
+	}
+}
+
+func TestRunWarningsOncePerResult(t *testing.T) {
+
+	data := `apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: tls
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.example.com
+    tls:
+      secretName: foo
+  routes:
+  - match: /
+    services:
+    - name: s1
+      port: 80
+      strategy: Random
+`
+
+	// The TLS IngressRoute becomes two HTTPRoutes, but its warning is only
+	// written once.
+	exitcode, output := runTranslate(t, data, "--target", "gatewayapi")
+	if exitcode != 0 {
+		t.Errorf("expected exit code 0, got %d", exitcode)
+	}
+	wantObjects := []string{"Gateway projectcontour/contour", "ReferenceGrant default/foo-from-projectcontour", "HTTPRoute default/tls", "HTTPRoute default/tls-http"}
+	if diff := cmp.Diff(outputObjects(t, output), wantObjects); diff != "" {
+		t.Errorf("output objects mismatch:\n%v", diff)
+	}
+	if got := strings.Count(output, "# IR2P-GATEWAY-LB-STRATEGY:"); got != 1 {
+		t.Errorf("expected the warning comment once, got %d in:\n%s", got, output)
+	}
+}
+
+func TestRunIngress(t *testing.T) {
+
+	data := `apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: blog
+  namespace: default
+spec:
+  rules:
+  - host: blog.example.com
+    http:
+      paths:
+      - path: /
+        backend:
+          serviceName: blog
+          servicePort: 80
+`
+
+	tests := map[string]struct {
+		args        []string
+		wantExit    int
+		wantObjects []string
+	}{
+		"an Ingress is an error by default": {
+			wantExit: 1,
+		},
+		"passed through unchanged": {
+			args:        []string{"--passthrough"},
+			wantObjects: []string{"Ingress default/blog"},
+		},
+		"translated": {
+			args:        []string{"--ingress"},
+			wantObjects: []string{"HTTPProxy default/blog"},
+		},
+		"translated with passthrough": {
+			args:        []string{"--ingress", "--passthrough"},
+			wantObjects: []string{"HTTPProxy default/blog"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			exitcode, output := runTranslate(t, data, tc.args...)
+			if exitcode != tc.wantExit {
+				t.Errorf("expected exit code %d, got %d", tc.wantExit, exitcode)
+			}
+			if diff := cmp.Diff(outputObjects(t, output), tc.wantObjects); diff != "" {
+				t.Errorf("output objects mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+// runTranslate runs ir2proxy with args on a file holding data, and returns
+// its exit code and what it wrote to stdout.
+func runTranslate(t *testing.T, data string, args ...string) (int, string) {
+
+	dir, err := ioutil.TempDir("", "ir2proxy-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "input.yaml")
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+
+	savedArgs, savedStdout, savedLogOutput := os.Args, os.Stdout, logrus.StandardLogger().Out
+	defer func() {
+		os.Args, os.Stdout = savedArgs, savedStdout
+		logrus.SetOutput(savedLogOutput)
+	}()
+	os.Args = append(append([]string{"ir2proxy"}, args...), file)
+	os.Stdout = stdout
+	logrus.SetOutput(ioutil.Discard)
+
+	exitcode := run()
+
+	output, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exitcode, string(output)
+}
+
+// outputObjects returns the kind, namespace and name of each object in
+// the output.
+func outputObjects(t *testing.T, output string) []string {
+
+	var objects []string
+	documents := input.NewDocumentReader(bytes.NewReader([]byte(output)))
+	for {
+		doc, err := documents.Read()
+		if err == io.EOF {
+			return objects
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var object struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal(doc.Data, &object); err != nil {
+			t.Fatal(err)
+		}
+		if object.Kind == "" {
+			continue
+		}
+		objects = append(objects, fmt.Sprintf("%s %s/%s", object.Kind, object.Metadata.Namespace, object.Metadata.Name))
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcontour/ir2proxy/internal/warning"
+)
+
+// The formats that results can be output in.
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+)
+
+// result is what's output for one object in the input.
+type result struct {
+	// source is where the object was read from. It's nil for objects that
+	// are generated for the whole input, like a Gateway.
+	source *source
+	// object is the input object, if it could be decoded.
+	object *warning.Object
+	// objects are the translated objects, or the object that's passed through.
+	objects []interface{}
+	// passthrough is true if the object is output unchanged.
+	passthrough bool
+	// document is the YAML document of an object that's passed through, as
+	// it was read.
+	document []byte
+	warnings []warning.Warning
+	errors   []string
+	// files are the files the objects were written to, with --output-dir.
+	files []string
+}
+
+// describe names the object, for messages about the whole result.
+func (r *result) describe() string {
+	switch {
+	case r.object != nil && r.source != nil:
+		return fmt.Sprintf("%s at %s:%d", r.object, r.source.file, r.source.line)
+	case r.object != nil:
+		return r.object.String()
+	case r.source != nil:
+		return fmt.Sprintf("the object at %s:%d", r.source.file, r.source.line)
+	default:
+		return "the shared objects"
+	}
+}
+
+// fail logs err, and records it against the result.
+func (r *result) fail(log *logrus.Entry, err error) {
+	log.Error(err)
+	r.errors = append(r.errors, err.Error())
+}
+
+// warn logs warnings, and records them against the result.
+func (r *result) warn(log *logrus.Entry, warnings []warning.Warning) {
+	for _, w := range warnings {
+		logWarning(log, w)
+	}
+	r.warnings = append(r.warnings, warnings...)
+}
+
+// render renders the object at index in the result. The warnings are about
+// the whole result, so they're only added to its first object.
+func (r *result) render(index int) ([]byte, error) {
+	var warnings []warning.Warning
+	if index == 0 {
+		warnings = r.warnings
+	}
+	return render(r.objects[index], warnings)
+}
+
+// failed returns true if the result has errors, and so has nothing to output.
+func (r *result) failed() bool {
+	return len(r.errors) > 0 || warning.HasErrors(r.warnings)
+}
+
+// report holds the results for all the objects in the input, in the order
+// they're output.
+type report struct {
+	results []*result
+	// warnings and errors that aren't about a single object.
+	warnings []warning.Warning
+	errors   []string
+}
+
+// add adds a result for the object read from src.
+func (r *report) add(src *source) *result {
+	res := &result{source: src}
+	r.results = append(r.results, res)
+	return res
+}
+
+// fail logs err, and records it against the whole report.
+func (r *report) fail(log *logrus.Entry, err error) {
+	log.Error(err)
+	r.errors = append(r.errors, err.Error())
+}
+
+// warn logs warnings, and records them against the whole report.
+func (r *report) warn(log *logrus.Entry, warnings []warning.Warning) {
+	for _, w := range warnings {
+		logWarning(log, w)
+	}
+	r.warnings = append(r.warnings, warnings...)
+}
+
+// exitcode returns 1 if there were any errors.
+func (r *report) exitcode() int {
+	if len(r.errors) > 0 || warning.HasErrors(r.warnings) {
+		return 1
+	}
+	for _, res := range r.results {
+		if res.failed() {
+			return 1
+		}
+	}
+	return 0
+}
+
+// write writes the output in format.
+func (r *report) write(w io.Writer, format string) error {
+	if format == formatJSON {
+		return r.writeJSON(w)
+	}
+	return r.writeYAML(w)
+}
+
+// writeYAML writes the output objects as YAML documents, with their warnings
+// as comments.
+func (r *report) writeYAML(w io.Writer) error {
+	for _, res := range r.results {
+		if res.failed() {
+			continue
+		}
+		if res.document != nil {
+			if _, err := w.Write(res.document); err != nil {
+				return err
+			}
+			continue
+		}
+		for index := range res.objects {
+			data, err := res.render(index)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonReport is the document written by --output json.
+type jsonReport struct {
+	Objects  []jsonResult      `json:"objects"`
+	Warnings []warning.Warning `json:"warnings,omitempty"`
+	Errors   []string          `json:"errors,omitempty"`
+	Summary  jsonSummary       `json:"summary"`
+}
+
+// jsonResult is the result for one object in the input.
+type jsonResult struct {
+	Source      *jsonSource       `json:"source,omitempty"`
+	Object      *warning.Object   `json:"object,omitempty"`
+	Output      []interface{}     `json:"output"`
+	Passthrough bool              `json:"passthrough,omitempty"`
+	Files       []string          `json:"files,omitempty"`
+	Warnings    []warning.Warning `json:"warnings,omitempty"`
+	Errors      []string          `json:"errors,omitempty"`
+}
+
+type jsonSource struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Item is the position of the object in the List it came from.
+	Item *int `json:"item,omitempty"`
+}
+
+// jsonSummary counts the objects in the input, and what happened to them.
+type jsonSummary struct {
+	Objects     int `json:"objects"`
+	Translated  int `json:"translated"`
+	Passthrough int `json:"passthrough"`
+	Failed      int `json:"failed"`
+	Warnings    int `json:"warnings"`
+	Errors      int `json:"errors"`
+}
+
+// count adds warnings and errors to the summary.
+func (s *jsonSummary) count(warnings []warning.Warning, errors []string) {
+	s.Errors += len(errors)
+	for _, w := range warnings {
+		if w.Severity == warning.SeverityError {
+			s.Errors++
+		} else {
+			s.Warnings++
+		}
+	}
+}
+
+// writeJSON writes the results as a single JSON document.
+func (r *report) writeJSON(w io.Writer) error {
+	out := jsonReport{
+		Objects:  []jsonResult{},
+		Warnings: r.warnings,
+		Errors:   r.errors,
+	}
+	out.Summary.count(r.warnings, r.errors)
+
+	for _, res := range r.results {
+		jsonRes := jsonResult{
+			Object:      res.object,
+			Output:      []interface{}{},
+			Passthrough: res.passthrough,
+			Files:       res.files,
+			Warnings:    res.warnings,
+			Errors:      res.errors,
+		}
+		if !res.failed() {
+			jsonRes.Output = append(jsonRes.Output, res.objects...)
+		}
+		out.Summary.count(res.warnings, res.errors)
+
+		if res.source != nil {
+			jsonRes.Source = &jsonSource{File: res.source.file, Line: res.source.line}
+			if res.source.item >= 0 {
+				item := res.source.item
+				jsonRes.Source.Item = &item
+			}
+			out.Summary.Objects++
+			switch {
+			case res.failed():
+				out.Summary.Failed++
+			case res.passthrough:
+				out.Summary.Passthrough++
+			default:
+				out.Summary.Translated++
+			}
+		}
+		out.Objects = append(out.Objects, jsonRes)
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
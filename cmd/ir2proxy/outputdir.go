@@ -0,0 +1,271 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcontour/ir2proxy/internal/input"
+)
+
+// The layouts of the files written by --output-dir.
+const (
+	// layoutObject writes each object to <dir>/<namespace>/<name>.<kind>.yaml.
+	layoutObject = "object"
+	// layoutMirror writes the objects from each input file to the same
+	// path under <dir>.
+	layoutMirror = "mirror"
+)
+
+// outputOptions are the flags that control where the output goes.
+type outputOptions struct {
+	format string
+	// dir is the directory to write files to, or empty for stdout.
+	dir    string
+	layout string
+	// force overwrites files that already exist in dir.
+	force bool
+	// inPlace replaces the objects in the input files instead.
+	inPlace bool
+}
+
+// finish writes the output of the report, and returns the exit code.
+func finish(rep *report, out outputOptions) int {
+	log := logrus.StandardLogger()
+
+	if out.inPlace {
+		if err := rep.rewriteInPlace(); err != nil {
+			log.Error(err)
+			return 1
+		}
+		if out.format != formatJSON {
+			return rep.exitcode()
+		}
+	}
+
+	if out.dir != "" {
+		if err := rep.writeDir(out.dir, out.layout, out.force); err != nil {
+			log.Error(err)
+			return 1
+		}
+		// Only the JSON report goes to stdout, since the objects are in
+		// the files.
+		if out.format != formatJSON {
+			return rep.exitcode()
+		}
+	}
+
+	// Objects with errors aren't output. The errors have already been
+	// logged.
+	if err := rep.write(os.Stdout, out.format); err != nil {
+		log.Error(err)
+		return 1
+	}
+	return rep.exitcode()
+}
+
+// outputFile is a file to be written under the output directory.
+type outputFile struct {
+	path string
+	data []byte
+	// owners describes what the file is written for, for reporting
+	// collisions.
+	owners []string
+}
+
+// writeDir writes the output objects to files under dir. Nothing is written
+// if any of the files would collide, or already exist and force is false.
+func (r *report) writeDir(dir string, layout string, force bool) error {
+
+	files, problems := r.planFiles(dir, layout)
+
+	log := logrus.StandardLogger()
+	for _, file := range files {
+		if len(file.owners) > 1 {
+			problems = append(problems, fmt.Sprintf("can't write %s, it would be written for each of %s", file.path, strings.Join(file.owners, ", ")))
+			continue
+		}
+		if _, err := os.Stat(file.path); err == nil && !force {
+			problems = append(problems, fmt.Sprintf("can't write %s, it already exists. Use --force to overwrite it", file.path))
+		}
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			log.Error(problem)
+		}
+		return fmt.Errorf("no files were written to %s", dir)
+	}
+
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file.path, file.data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planFiles returns the files to write the output objects to, in the order
+// they're first written to, and any problems that mean they can't be written.
+func (r *report) planFiles(dir string, layout string) ([]*outputFile, []string) {
+
+	var files []*outputFile
+	var problems []string
+	byPath := make(map[string]*outputFile)
+	add := func(res *result, owner string, path string, data []byte) {
+		file, ok := byPath[path]
+		if !ok {
+			file = &outputFile{path: path}
+			byPath[path] = file
+			files = append(files, file)
+		}
+		if len(file.owners) == 0 || file.owners[len(file.owners)-1] != owner {
+			file.owners = append(file.owners, owner)
+		}
+		file.data = append(file.data, data...)
+		if len(res.files) == 0 || res.files[len(res.files)-1] != path {
+			res.files = append(res.files, path)
+		}
+	}
+
+	root := ""
+	if layout == layoutMirror {
+		var err error
+		root, err = r.inputRoot()
+		if err != nil {
+			return nil, []string{err.Error()}
+		}
+	}
+
+	for _, res := range r.results {
+		if res.failed() {
+			continue
+		}
+
+		// Objects that weren't read from a file, like a Gateway, are
+		// written to a file of their own in either layout.
+		if layout == layoutMirror && res.source != nil {
+			abs, err := filepath.Abs(res.source.file)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			data := res.document
+			if data == nil {
+				for index := range res.objects {
+					rendered, err := res.render(index)
+					if err != nil {
+						problems = append(problems, err.Error())
+						continue
+					}
+					data = append(data, rendered...)
+				}
+			}
+			add(res, res.source.file, filepath.Join(dir, rel), data)
+			continue
+		}
+
+		for index, obj := range res.objects {
+			path, err := objectPath(dir, obj)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("can't write %s, %s", res.describe(), err))
+				continue
+			}
+			data, err := res.render(index)
+			if err != nil {
+				problems = append(problems, err.Error())
+				continue
+			}
+			add(res, res.describe(), path, data)
+		}
+	}
+	return files, problems
+}
+
+// inputRoot returns the deepest directory that holds all the input files.
+func (r *report) inputRoot() (string, error) {
+	root := ""
+	for _, res := range r.results {
+		if res.source == nil {
+			continue
+		}
+		if res.source.file == input.Stdin {
+			return "", fmt.Errorf("--layout=%s can't be used with stdin", layoutMirror)
+		}
+		abs, err := filepath.Abs(res.source.file)
+		if err != nil {
+			return "", err
+		}
+		dir := filepath.Dir(abs)
+		if root == "" {
+			root = dir
+			continue
+		}
+		for !within(root, dir) && filepath.Dir(root) != root {
+			root = filepath.Dir(root)
+		}
+	}
+	return root, nil
+}
+
+// within returns true if path is dir, or is under it.
+func within(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// objectPath returns <dir>/<namespace>/<name>.<kind>.yaml for an object,
+// or <dir>/<name>.<kind>.yaml if it has no namespace.
+func objectPath(dir string, obj interface{}) (string, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	var meta struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return "", err
+	}
+	if meta.Kind == "" || meta.Metadata.Name == "" {
+		return "", fmt.Errorf("an object needs a kind and a name to be written to a file of its own")
+	}
+	// Names can't have path separators in Kubernetes, but the input might
+	// not have been checked.
+	for _, element := range []string{meta.Kind, meta.Metadata.Name, meta.Metadata.Namespace} {
+		if strings.ContainsAny(element, `/\`) || element == ".." {
+			return "", fmt.Errorf("%q can't be used in a file name", element)
+		}
+	}
+	name := fmt.Sprintf("%s.%s.yaml", meta.Metadata.Name, strings.ToLower(meta.Kind))
+	return filepath.Join(dir, meta.Metadata.Namespace, name), nil
+}
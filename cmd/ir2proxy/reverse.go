@@ -0,0 +1,85 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcontour/ir2proxy/internal/input"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/translator"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+)
+
+// runReverse translates the HTTPProxy objects in files back to IngressRoutes,
+// and returns the exit code.
+func runReverse(files []string, passthrough bool, policy warning.Policy, out outputOptions) int {
+
+	log := logrus.StandardLogger()
+	var rep report
+
+	// Decode all the HTTPProxies first, so that includes can be followed
+	// across all the files.
+	var hps []*hpv1.HTTPProxy
+	var hpResults []*result
+	for _, file := range files {
+		if err := readFile(file, func(doc *input.Document) {
+			docSource := source{file: file, line: doc.Line, item: -1}
+			items, err := k8sdecoder.Decode(doc.Data)
+			if err != nil {
+				rep.add(&docSource).fail(log.WithFields(docSource.fields()), err)
+				return
+			}
+
+			for _, item := range items {
+				itemSource := source{file: file, line: doc.Line, item: item.Index}
+				itemLog := log.WithFields(itemSource.fields())
+				res := rep.add(&itemSource)
+				hp, ok := item.Object.(*hpv1.HTTPProxy)
+				if !ok && passthrough {
+					passthroughResult(res, itemLog, doc, item)
+					continue
+				}
+				if !ok {
+					res.fail(itemLog, fmt.Errorf("can only parse HTTPProxy with --reverse, a %s was supplied", item.GroupVersionKind))
+					continue
+				}
+
+				res.object = &warning.Object{Kind: "HTTPProxy", Namespace: hp.Namespace, Name: hp.Name}
+				ignoreAnnotated(itemLog, &policy, *res.object, hp.Annotations)
+
+				hps = append(hps, hp)
+				hpResults = append(hpResults, res)
+			}
+		}); err != nil {
+			rep.fail(log.WithField("file", file), err)
+		}
+	}
+
+	for index, translation := range translator.HTTPProxiesToIngressRoutes(hps) {
+		hp, res := translation.HTTPProxy, hpResults[index]
+		objectLog := log.WithFields(res.source.fields()).WithField("httpproxy", hp.Namespace+"/"+hp.Name)
+		if translation.Err != nil {
+			res.fail(objectLog, translation.Err)
+			continue
+		}
+		res.warn(objectLog, policy.Apply(translation.Warnings))
+		res.objects = []interface{}{translation.IngressRoute}
+	}
+
+	return finish(&rep, out)
+}
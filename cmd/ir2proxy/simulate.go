@@ -0,0 +1,131 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcontour/ir2proxy/internal/delegation"
+	"github.com/projectcontour/ir2proxy/internal/input"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/routing"
+	"github.com/projectcontour/ir2proxy/internal/translator"
+)
+
+// runSimulate resolves requests against the IngressRoutes and the HTTPProxies
+// in files, and reports the requests that are routed differently. If there are
+// no HTTPProxies, the IngressRoutes are translated to get them.
+// It returns the exit code, which is 1 if any request is routed differently.
+func runSimulate(files []string, rawRequests []string) int {
+
+	log := logrus.StandardLogger()
+	exitcode := 0
+
+	var requests []routing.Request
+	for _, rawRequest := range rawRequests {
+		request, err := routing.ParseRequest(rawRequest)
+		if err != nil {
+			log.Error(err)
+			return 1
+		}
+		requests = append(requests, request)
+	}
+
+	var irs []*irv1beta1.IngressRoute
+	var hps []*hpv1.HTTPProxy
+	for _, file := range files {
+		if err := readFile(file, func(doc *input.Document) {
+			items, err := k8sdecoder.Decode(doc.Data)
+			if err != nil {
+				log.WithFields(source{file: file, line: doc.Line, item: -1}.fields()).Error(err)
+				exitcode = 1
+				return
+			}
+			// Other objects don't change where requests are routed.
+			for _, item := range items {
+				switch obj := item.Object.(type) {
+				case *irv1beta1.IngressRoute:
+					irs = append(irs, obj)
+				case *hpv1.HTTPProxy:
+					hps = append(hps, obj)
+				}
+			}
+		}); err != nil {
+			log.WithField("file", file).Error(err)
+			exitcode = 1
+		}
+	}
+	if exitcode != 0 {
+		return exitcode
+	}
+
+	if len(irs) == 0 {
+		log.Error("no IngressRoutes were supplied to simulate")
+		return 1
+	}
+
+	if len(hps) == 0 {
+		// The requests for an IngressRoute that can't be translated are
+		// reported as routed differently.
+		for _, translation := range translator.IngressRoutesToHTTPProxies(irs) {
+			if translation.Err != nil {
+				log.WithField("ingressroute", delegation.KeyOf(translation.IngressRoute)).Error(translation.Err)
+				continue
+			}
+			hps = append(hps, translation.HTTPProxy)
+		}
+	}
+
+	irRoutes := routing.FromIngressRoutes(irs)
+	hpRoutes := routing.FromHTTPProxies(hps)
+
+	// Requests that were asked for are always reported, but requests for
+	// each route are only reported if they're routed differently.
+	reportAll := len(requests) > 0
+	if !reportAll {
+		requests = routing.ProbeRequests(irRoutes, hpRoutes)
+	}
+
+	different := 0
+	for _, request := range requests {
+		irResolution := routing.Resolve(irRoutes, request)
+		hpResolution := routing.Resolve(hpRoutes, request)
+		same := irResolution.SameDestination(hpResolution)
+		if !same {
+			different++
+		}
+		if same && !reportAll {
+			continue
+		}
+
+		status := "same"
+		if !same {
+			status = "DIFFERENT"
+		}
+		fmt.Printf("%s: %s\n", request, status)
+		fmt.Printf("  IngressRoute: %s\n", irResolution)
+		fmt.Printf("  HTTPProxy:    %s\n", hpResolution)
+	}
+
+	if different > 0 {
+		fmt.Printf("%d of %d requests are routed differently.\n", different, len(requests))
+		return 1
+	}
+	fmt.Printf("All %d requests are routed the same.\n", len(requests))
+	return 0
+}
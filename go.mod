@@ -9,6 +9,8 @@ require (
 	github.com/projectcontour/contour v1.1.0
 	github.com/sirupsen/logrus v1.4.2
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/yaml.v3 v3.0.1
+	k8s.io/api v0.0.0-20190918195907-bd6ac527cfd2
 	k8s.io/apimachinery v0.0.0-20190913080033-27d36303b655
 	k8s.io/client-go v0.0.0-20190918200256-06eb1244587a
 )
@@ -0,0 +1,186 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit finds IngressRoute fields that aren't translated to HTTPProxy
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+)
+
+// consumedPaths are the fields of an IngressRoute that are read by
+// translator.IngressRouteToHTTPProxy, with any list index replaced by [*].
+// A path also covers every field below it.
+// Fields the translator reads only to warn that they can't be applied
+// are included here, since they don't vanish silently.
+// Each of them is checked to change the translation in
+// TestConsumedPathsAreTranslated.
+var consumedPaths = []string{
+	".metadata.name",
+	".metadata.namespace",
+	".metadata.labels",
+	".metadata.annotations",
+	".spec.virtualhost",
+	".spec.routes[*].match",
+	".spec.routes[*].delegate.name",
+	".spec.routes[*].delegate.namespace",
+	".spec.routes[*].enableWebsockets",
+	".spec.routes[*].permitInsecure",
+	".spec.routes[*].prefixRewrite",
+	".spec.routes[*].timeoutPolicy.request",
+	".spec.routes[*].retryPolicy.count",
+	".spec.routes[*].retryPolicy.perTryTimeout",
+	".spec.routes[*].services[*].name",
+	".spec.routes[*].services[*].port",
+	".spec.routes[*].services[*].weight",
+	".spec.routes[*].services[*].strategy",
+	".spec.routes[*].services[*].healthCheck",
+	".spec.routes[*].services[*].validation",
+	".spec.tcpproxy.delegate.name",
+	".spec.tcpproxy.delegate.namespace",
+	".spec.tcpproxy.services[*].name",
+	".spec.tcpproxy.services[*].port",
+	".spec.tcpproxy.services[*].weight",
+	".spec.tcpproxy.services[*].strategy",
+	".spec.tcpproxy.services[*].healthCheck",
+	".spec.tcpproxy.services[*].validation",
+}
+
+// ignoredPaths are fields that are populated by the API server, or that give
+// the type of the object, and so aren't expected to carry over to a new object.
+var ignoredPaths = []string{
+	".apiVersion",
+	".kind",
+	".metadata.uid",
+	".metadata.resourceVersion",
+	".metadata.generation",
+	".metadata.creationTimestamp",
+	".metadata.selfLink",
+	".metadata.managedFields",
+	".status",
+}
+
+var listIndex = regexp.MustCompile(`\[[0-9]+\]`)
+
+var simpleKey = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// DroppedField is a field that's set in an IngressRoute but not
+// translated to HTTPProxy.
+type DroppedField struct {
+	// Path is the JSONPath of the field in the IngressRoute,
+	// like `.spec.routes[0].match`.
+	Path string
+	// Value is the value of the field, as decoded from JSON.
+	Value interface{}
+}
+
+func (d DroppedField) String() string {
+	return fmt.Sprintf("Field %s with value %v is not translated to HTTPProxy, discarding. Please check if it's needed.", d.Path, d.Value)
+}
+
+// Warning returns the warning for the dropped field. Its Object isn't set.
+func (d DroppedField) Warning() warning.Warning {
+	return warning.New(warning.SeverityWarning, warning.FieldDropped, d.Path, "%s", d)
+}
+
+// CheckIngressRoute walks an IngressRoute, and returns every field with
+// a non-zero value that the translator doesn't consume.
+func CheckIngressRoute(ir *irv1beta1.IngressRoute) ([]DroppedField, error) {
+
+	data, err := json.Marshal(ir)
+	if err != nil {
+		return nil, fmt.Errorf("could not audit IngressRoute, %s", err)
+	}
+
+	var fields interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("could not audit IngressRoute, %s", err)
+	}
+
+	var dropped []DroppedField
+	walk("", fields, func(path string, value interface{}) {
+		if !covered(path, consumedPaths) && !covered(path, ignoredPaths) {
+			dropped = append(dropped, DroppedField{
+				Path:  path,
+				Value: value,
+			})
+		}
+	})
+
+	return dropped, nil
+}
+
+// walk calls visit for each non-zero leaf value under value.
+func walk(path string, value interface{}, visit func(string, interface{})) {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// Sort the keys so the output order is stable.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walk(path+pathKey(key), v[key], visit)
+		}
+	case []interface{}:
+		for index, item := range v {
+			walk(fmt.Sprintf("%s[%d]", path, index), item, visit)
+		}
+	case nil:
+	case string:
+		if v != "" {
+			visit(path, v)
+		}
+	case float64:
+		if v != 0 {
+			visit(path, v)
+		}
+	case bool:
+		if v {
+			visit(path, v)
+		}
+	default:
+		visit(path, v)
+	}
+}
+
+// pathKey returns the JSONPath element for a map key, using the
+// bracket notation for keys that aren't plain identifiers.
+func pathKey(key string) string {
+	if simpleKey.MatchString(key) {
+		return "." + key
+	}
+	return fmt.Sprintf("['%s']", key)
+}
+
+// covered returns true if path is one of the given paths, or is a field
+// below one of them.
+func covered(path string, paths []string) bool {
+
+	path = listIndex.ReplaceAllString(path, "[*]")
+	for _, p := range paths {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,404 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/translator"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckIngressRoute(t *testing.T) {
+
+	tests := map[string]struct {
+		input []byte
+		want  []DroppedField
+	}{
+		"fully translated IngressRoute": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: basic
+  namespace: default
+  labels:
+    app: kuard
+  annotations:
+    kubernetes.io/ingress.class: contour
+spec:
+  virtualhost:
+    fqdn: foo-basic.bar.com
+    tls:
+      secretName: secret
+  routes:
+    - match: /
+      enableWebsockets: true
+      retryPolicy:
+        count: 3
+      services:
+        - name: s1
+          port: 80
+          healthCheck:
+            path: /healthy
+            intervalSeconds: 5
+`),
+			want: nil,
+		},
+		"server populated fields": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: basic
+  namespace: default
+  uid: 5a0f4c9b-0a9b-4b4e-9d43-1f2a3b4c5d6e
+  resourceVersion: "1234"
+  generation: 2
+  creationTimestamp: "2019-11-01T00:00:00Z"
+spec:
+  virtualhost:
+    fqdn: foo-basic.bar.com
+  routes:
+    - match: /
+      services:
+        - name: s1
+          port: 80
+status:
+  currentStatus: valid
+  description: valid IngressRoute
+`),
+			want: nil,
+		},
+		"dropped metadata": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: basic
+  namespace: default
+  finalizers:
+    - example.com/cleanup
+  ownerReferences:
+    - apiVersion: apps/v1
+      kind: Deployment
+      name: kuard
+      uid: 5a0f4c9b-0a9b-4b4e-9d43-1f2a3b4c5d6e
+spec:
+  routes:
+    - match: /
+      services:
+        - name: s1
+          port: 80
+`),
+			want: []DroppedField{
+				{Path: ".metadata.finalizers[0]", Value: "example.com/cleanup"},
+				{Path: ".metadata.ownerReferences[0].apiVersion", Value: "apps/v1"},
+				{Path: ".metadata.ownerReferences[0].kind", Value: "Deployment"},
+				{Path: ".metadata.ownerReferences[0].name", Value: "kuard"},
+				{Path: ".metadata.ownerReferences[0].uid", Value: "5a0f4c9b-0a9b-4b4e-9d43-1f2a3b4c5d6e"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ir, err := k8sdecoder.DecodeIngressRoute(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := CheckIngressRoute(ir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+// TestCheckTranslatorFixtures checks that none of the translator test inputs
+// have fields that would be dropped.
+func TestCheckTranslatorFixtures(t *testing.T) {
+
+	testdataFiles, err := ioutil.ReadDir("../translator/testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileinfo := range testdataFiles {
+		// testdata/fuzz holds the corpus of the translator fuzz tests.
+		if !fileinfo.IsDir() || fileinfo.Name() == "fuzz" {
+			continue
+		}
+		t.Run(fileinfo.Name(), func(t *testing.T) {
+			input, err := ioutil.ReadFile(fmt.Sprintf("../translator/testdata/%s/input.yaml", fileinfo.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			items, err := k8sdecoder.Decode(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ir, ok := items[0].Object.(*irv1beta1.IngressRoute)
+			if !ok {
+				t.Skipf("not an IngressRoute, a %s", items[0].GroupVersionKind)
+			}
+			dropped, err := CheckIngressRoute(ir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dropped) > 0 {
+				t.Fatalf("unexpected dropped fields: %v", dropped)
+			}
+		})
+	}
+}
+
+func TestPathKey(t *testing.T) {
+
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"identifier": {
+			input: "fqdn",
+			want:  ".fqdn",
+		},
+		"dotted key": {
+			input: "kubernetes.io/ingress.class",
+			want:  "['kubernetes.io/ingress.class']",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := pathKey(tc.input)
+			if got != tc.want {
+				t.Fatalf("expected: '%v', got '%v'", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestConsumedPathsAreTranslated checks that every path in consumedPaths is
+// really read by the translator, by changing the field and checking that
+// the translation changes too. Without this, a path the translator stopped
+// reading would still be counted as translated.
+func TestConsumedPathsAreTranslated(t *testing.T) {
+
+	// A change is applied to an IngressRoute after setup, if there is one,
+	// and must change the translation of it.
+	type change struct {
+		setup  func(ir *irv1beta1.IngressRoute)
+		change func(ir *irv1beta1.IngressRoute)
+	}
+	service := func(ir *irv1beta1.IngressRoute) *irv1beta1.Service {
+		return &ir.Spec.Routes[0].Services[0]
+	}
+	tcpService := func(ir *irv1beta1.IngressRoute) *irv1beta1.Service {
+		return &ir.Spec.TCPProxy.Services[0]
+	}
+	delegate := func(ir *irv1beta1.IngressRoute) {
+		ir.Spec.Routes = append(ir.Spec.Routes, irv1beta1.Route{
+			Match:    "/child",
+			Delegate: &irv1beta1.Delegate{Name: "child", Namespace: "default"},
+		})
+	}
+	tcpProxy := func(ir *irv1beta1.IngressRoute) {
+		ir.Spec.TCPProxy = &irv1beta1.TCPProxy{
+			Services: []irv1beta1.Service{{Name: "tcp", Port: 443}},
+		}
+	}
+	tcpDelegate := func(ir *irv1beta1.IngressRoute) {
+		ir.Spec.TCPProxy = &irv1beta1.TCPProxy{
+			Delegate: &irv1beta1.Delegate{Name: "tcp", Namespace: "default"},
+		}
+	}
+	healthCheck := &irv1beta1.HealthCheck{Path: "/healthy"}
+	validation := &hpv1.UpstreamValidation{CACertificate: "ca", SubjectName: "upstream"}
+
+	changes := map[string][]change{
+		".metadata.name":        {{change: func(ir *irv1beta1.IngressRoute) { ir.Name = "other" }}},
+		".metadata.namespace":   {{change: func(ir *irv1beta1.IngressRoute) { ir.Namespace = "other" }}},
+		".metadata.labels":      {{change: func(ir *irv1beta1.IngressRoute) { ir.Labels = map[string]string{"app": "kuard"} }}},
+		".metadata.annotations": {{change: func(ir *irv1beta1.IngressRoute) { ir.Annotations = map[string]string{"team": "kuard"} }}},
+		".spec.virtualhost": {
+			{change: func(ir *irv1beta1.IngressRoute) { ir.Spec.VirtualHost.Fqdn = "other.example.com" }},
+			{change: func(ir *irv1beta1.IngressRoute) {
+				ir.Spec.VirtualHost.TLS = &hpv1.TLS{SecretName: "secret"}
+			}},
+			{change: func(ir *irv1beta1.IngressRoute) {
+				ir.Spec.VirtualHost.TLS = &hpv1.TLS{MinimumProtocolVersion: "1.3"}
+			}},
+			{change: func(ir *irv1beta1.IngressRoute) {
+				ir.Spec.VirtualHost.TLS = &hpv1.TLS{Passthrough: true}
+			}},
+		},
+		".spec.routes[*].match": {{change: func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[0].Match = "/other" }}},
+		".spec.routes[*].delegate.name": {{
+			setup:  delegate,
+			change: func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[1].Delegate.Name = "other" },
+		}},
+		".spec.routes[*].delegate.namespace": {{
+			setup:  delegate,
+			change: func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[1].Delegate.Namespace = "other" },
+		}},
+		".spec.routes[*].enableWebsockets": {{change: func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[0].EnableWebsockets = true }}},
+		".spec.routes[*].permitInsecure":   {{change: func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[0].PermitInsecure = true }}},
+		".spec.routes[*].prefixRewrite":    {{change: func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[0].PrefixRewrite = "/app" }}},
+		".spec.routes[*].timeoutPolicy.request": {{change: func(ir *irv1beta1.IngressRoute) {
+			ir.Spec.Routes[0].TimeoutPolicy = &irv1beta1.TimeoutPolicy{Request: "1s"}
+		}}},
+		".spec.routes[*].retryPolicy.count": {{change: func(ir *irv1beta1.IngressRoute) {
+			ir.Spec.Routes[0].RetryPolicy = &hpv1.RetryPolicy{NumRetries: 3}
+		}}},
+		".spec.routes[*].retryPolicy.perTryTimeout": {{change: func(ir *irv1beta1.IngressRoute) {
+			ir.Spec.Routes[0].RetryPolicy = &hpv1.RetryPolicy{PerTryTimeout: "150ms"}
+		}}},
+		".spec.routes[*].services[*].name":     {{change: func(ir *irv1beta1.IngressRoute) { service(ir).Name = "other" }}},
+		".spec.routes[*].services[*].port":     {{change: func(ir *irv1beta1.IngressRoute) { service(ir).Port = 8080 }}},
+		".spec.routes[*].services[*].weight":   {{change: func(ir *irv1beta1.IngressRoute) { service(ir).Weight = 10 }}},
+		".spec.routes[*].services[*].strategy": {{change: func(ir *irv1beta1.IngressRoute) { service(ir).Strategy = "Random" }}},
+		".spec.routes[*].services[*].healthCheck": {
+			{change: func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck = healthCheck.DeepCopy() }},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck = healthCheck.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck.Path = "/other" },
+			},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck = healthCheck.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck.Host = "other.example.com" },
+			},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck = healthCheck.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck.IntervalSeconds = 5 },
+			},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck = healthCheck.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck.TimeoutSeconds = 2 },
+			},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck = healthCheck.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck.UnhealthyThresholdCount = 3 },
+			},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck = healthCheck.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).HealthCheck.HealthyThresholdCount = 3 },
+			},
+		},
+		".spec.routes[*].services[*].validation": {
+			{change: func(ir *irv1beta1.IngressRoute) { service(ir).UpstreamValidation = validation.DeepCopy() }},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).UpstreamValidation = validation.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).UpstreamValidation.CACertificate = "other" },
+			},
+			{
+				setup:  func(ir *irv1beta1.IngressRoute) { service(ir).UpstreamValidation = validation.DeepCopy() },
+				change: func(ir *irv1beta1.IngressRoute) { service(ir).UpstreamValidation.SubjectName = "other" },
+			},
+		},
+		".spec.tcpproxy.delegate.name": {{
+			setup:  tcpDelegate,
+			change: func(ir *irv1beta1.IngressRoute) { ir.Spec.TCPProxy.Delegate.Name = "other" },
+		}},
+		".spec.tcpproxy.delegate.namespace": {{
+			setup:  tcpDelegate,
+			change: func(ir *irv1beta1.IngressRoute) { ir.Spec.TCPProxy.Delegate.Namespace = "other" },
+		}},
+		".spec.tcpproxy.services[*].name": {{
+			setup:  tcpProxy,
+			change: func(ir *irv1beta1.IngressRoute) { tcpService(ir).Name = "other" },
+		}},
+		".spec.tcpproxy.services[*].port": {{
+			setup:  tcpProxy,
+			change: func(ir *irv1beta1.IngressRoute) { tcpService(ir).Port = 8443 },
+		}},
+		".spec.tcpproxy.services[*].weight": {{
+			setup:  tcpProxy,
+			change: func(ir *irv1beta1.IngressRoute) { tcpService(ir).Weight = 10 },
+		}},
+		".spec.tcpproxy.services[*].strategy": {{
+			setup:  tcpProxy,
+			change: func(ir *irv1beta1.IngressRoute) { tcpService(ir).Strategy = "Random" },
+		}},
+		// A health check on a tcpproxy service has no effect, so the
+		// translator only warns that it's there.
+		".spec.tcpproxy.services[*].healthCheck": {{
+			setup:  tcpProxy,
+			change: func(ir *irv1beta1.IngressRoute) { tcpService(ir).HealthCheck = healthCheck.DeepCopy() },
+		}},
+		".spec.tcpproxy.services[*].validation": {{
+			setup:  tcpProxy,
+			change: func(ir *irv1beta1.IngressRoute) { tcpService(ir).UpstreamValidation = validation.DeepCopy() },
+		}},
+	}
+
+	consumed := make(map[string]bool)
+	for _, path := range consumedPaths {
+		consumed[path] = true
+		if _, ok := changes[path]; !ok {
+			t.Errorf("%s is in consumedPaths, but has no change to check that it's translated", path)
+		}
+	}
+
+	translate := func(ir *irv1beta1.IngressRoute) string {
+		hp, warnings, err := translator.IngressRouteToHTTPProxy(ir)
+		data, marshalErr := json.Marshal(hp)
+		if marshalErr != nil {
+			t.Fatal(marshalErr)
+		}
+		return fmt.Sprintf("%s\n%v\n%v", data, warning.Strings(warnings), err)
+	}
+
+	for path, pathChanges := range changes {
+		if !consumed[path] {
+			t.Errorf("%s has a change, but isn't in consumedPaths", path)
+			continue
+		}
+		for index, c := range pathChanges {
+			t.Run(fmt.Sprintf("%s/%d", path, index), func(t *testing.T) {
+				ir := &irv1beta1.IngressRoute{
+					ObjectMeta: v1.ObjectMeta{Name: "basic", Namespace: "default"},
+					Spec: irv1beta1.IngressRouteSpec{
+						VirtualHost: &hpv1.VirtualHost{Fqdn: "basic.example.com"},
+						Routes: []irv1beta1.Route{{
+							Match:    "/",
+							Services: []irv1beta1.Service{{Name: "s1", Port: 80}},
+						}},
+					},
+				}
+				if c.setup != nil {
+					c.setup(ir)
+				}
+				before := translate(ir.DeepCopy())
+				c.change(ir)
+				if after := translate(ir); after == before {
+					t.Fatalf("changing %s didn't change the translation:\n%s", path, after)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delegation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcontour/ir2proxy/internal/warning"
+)
+
+// FindingKind is the kind of problem a Finding describes.
+type FindingKind string
+
+const (
+	// Cycle is a delegation chain that leads back to an IngressRoute already in it.
+	Cycle FindingKind = "cycle"
+	// Dangling is a delegation to an IngressRoute that isn't in the set.
+	Dangling FindingKind = "dangling"
+	// Unreachable is a nonroot IngressRoute that no root delegates to, directly or indirectly.
+	Unreachable FindingKind = "unreachable"
+	// ConflictingPrefixes is an IngressRoute that's delegated to at more than one prefix.
+	ConflictingPrefixes FindingKind = "conflicting-prefixes"
+)
+
+// Path is a chain of delegations through the graph.
+type Path []Edge
+
+func (p Path) String() string {
+
+	if len(p) == 0 {
+		return ""
+	}
+
+	elements := []string{p[0].Parent.String()}
+	for _, edge := range p {
+		if edge.TCPProxy {
+			elements = append(elements, "tcpproxy")
+		} else {
+			elements = append(elements, edge.Prefix)
+		}
+		elements = append(elements, edge.Child.String())
+	}
+	return strings.Join(elements, " -> ")
+}
+
+// Finding is a problem with the delegation graph.
+type Finding struct {
+	Kind FindingKind
+	// Key is the IngressRoute the problem should be reported against.
+	Key Key
+	// Paths are the delegation chains that lead to the problem.
+	Paths []Path
+}
+
+func (f Finding) String() string {
+
+	var paths []string
+	for _, path := range f.Paths {
+		paths = append(paths, path.String())
+	}
+
+	switch f.Kind {
+	case Cycle:
+		return fmt.Sprintf("Delegation cycle found: %s. Contour won't serve any of the routes in it.", strings.Join(paths, "; "))
+	case Dangling:
+		return fmt.Sprintf("Delegation to %s, which is not in the input: %s. Please check it exists.", f.Paths[0][len(f.Paths[0])-1].Child, strings.Join(paths, "; "))
+	case Unreachable:
+		if len(paths) == 0 {
+			return fmt.Sprintf("Nonroot IngressRoute %s is not delegated to by any IngressRoute in the input. Please check its parent exists.", f.Key)
+		}
+		return fmt.Sprintf("Nonroot IngressRoute %s is not reachable from any root IngressRoute in the input: %s. Please check its parents exist.", f.Key, strings.Join(paths, "; "))
+	case ConflictingPrefixes:
+		return fmt.Sprintf("IngressRoute %s is delegated to at conflicting prefixes: %s. HTTPProxy prefix conditions should not include the include prefix, so only one can be trimmed.", f.Key, strings.Join(paths, "; "))
+	}
+	return fmt.Sprintf("%s: %s", f.Kind, strings.Join(paths, "; "))
+}
+
+// findingCodes are the warning codes for each kind of Finding.
+var findingCodes = map[FindingKind]warning.Code{
+	Cycle:               warning.DelegationCycle,
+	Dangling:            warning.DelegationDangling,
+	Unreachable:         warning.DelegationUnreachable,
+	ConflictingPrefixes: warning.DelegationConflict,
+}
+
+// Warning returns the warning for the Finding, against the IngressRoute it
+// should be reported against.
+func (f Finding) Warning() warning.Warning {
+	w := warning.New(warning.SeverityWarning, findingCodes[f.Kind], "", "%s", f)
+	w.Object = warning.Object{Kind: "IngressRoute", Namespace: f.Key.Namespace, Name: f.Key.Name}
+	return w
+}
+
+type analysis struct {
+	graph *Graph
+	// paths holds the first path found to each visited IngressRoute.
+	paths map[Key]Path
+	// onPath holds the IngressRoutes in the chain currently being walked.
+	onPath       map[Key]bool
+	seenCycles   map[string]bool
+	seenDangling map[Edge]bool
+	findings     []Finding
+}
+
+// Analyze looks for cycles, delegations to IngressRoutes that aren't in the graph,
+// nonroot IngressRoutes that can't be reached from a root, and IngressRoutes
+// delegated to at conflicting prefixes.
+// Findings are returned in a stable order, following the order of the graph Keys.
+func Analyze(g *Graph) []Finding {
+
+	a := &analysis{
+		graph:        g,
+		paths:        make(map[Key]Path),
+		onPath:       make(map[Key]bool),
+		seenCycles:   make(map[string]bool),
+		seenDangling: make(map[Edge]bool),
+	}
+
+	for _, key := range g.Keys {
+		if g.IsRoot(key) {
+			a.walk(key, Path{})
+		}
+	}
+
+	// Anything not visited yet can't be reached from a root.
+	var unreachable []Key
+	for _, key := range g.Keys {
+		if _, ok := a.paths[key]; !ok && !g.IsRoot(key) {
+			unreachable = append(unreachable, key)
+		}
+	}
+	for _, key := range unreachable {
+		finding := Finding{
+			Kind: Unreachable,
+			Key:  key,
+		}
+		if ancestors := a.ancestors(key); len(ancestors) > 0 {
+			finding.Paths = []Path{ancestors}
+		}
+		a.findings = append(a.findings, finding)
+
+		// Walk the unreachable parts of the graph too, to find any
+		// cycles or dangling delegations in them.
+		if _, ok := a.paths[key]; !ok {
+			a.walk(key, Path{})
+		}
+	}
+
+	for _, key := range g.Keys {
+		if len(g.IncludePrefixes(key)) < 2 {
+			continue
+		}
+		finding := Finding{
+			Kind: ConflictingPrefixes,
+			Key:  key,
+		}
+		for _, edge := range g.Parents[key] {
+			if edge.TCPProxy {
+				continue
+			}
+			finding.Paths = append(finding.Paths, append(a.pathTo(edge.Parent), edge))
+		}
+		a.findings = append(a.findings, finding)
+	}
+
+	return a.findings
+}
+
+// walk does a depth-first walk of the graph from key, which was reached by path.
+func (a *analysis) walk(key Key, path Path) {
+
+	a.paths[key] = path
+	a.onPath[key] = true
+	defer delete(a.onPath, key)
+
+	for _, edge := range a.graph.Children[key] {
+		childPath := append(append(Path{}, path...), edge)
+
+		if _, ok := a.graph.Nodes[edge.Child]; !ok {
+			if !a.seenDangling[edge] {
+				a.seenDangling[edge] = true
+				a.findings = append(a.findings, Finding{
+					Kind:  Dangling,
+					Key:   edge.Parent,
+					Paths: []Path{childPath},
+				})
+			}
+			continue
+		}
+
+		if a.onPath[edge.Child] {
+			a.addCycle(childPath)
+			continue
+		}
+
+		if _, ok := a.paths[edge.Child]; ok {
+			// Already walked from here.
+			continue
+		}
+
+		a.walk(edge.Child, childPath)
+	}
+}
+
+// addCycle records the cycle at the end of path, which ends in an edge
+// back to an IngressRoute earlier in the path.
+func (a *analysis) addCycle(path Path) {
+
+	last := path[len(path)-1]
+	start := len(path) - 1
+	for start > 0 && path[start].Parent != last.Child {
+		start--
+	}
+	cycle := path[start:]
+
+	// The same cycle can be found starting from any IngressRoute in it,
+	// so only record it once.
+	var keys []string
+	for _, edge := range cycle {
+		keys = append(keys, edge.Parent.String())
+	}
+	id := cycleID(keys)
+	if a.seenCycles[id] {
+		return
+	}
+	a.seenCycles[id] = true
+
+	a.findings = append(a.findings, Finding{
+		Kind:  Cycle,
+		Key:   cycle[0].Parent,
+		Paths: []Path{path},
+	})
+}
+
+// cycleID returns the same string for every rotation of keys.
+func cycleID(keys []string) string {
+	smallest := 0
+	for i := range keys {
+		if keys[i] < keys[smallest] {
+			smallest = i
+		}
+	}
+	return strings.Join(append(append([]string{}, keys[smallest:]...), keys[:smallest]...), ",")
+}
+
+// pathTo returns the path to key found by the walk, or its ancestors if
+// it wasn't reached from a root.
+func (a *analysis) pathTo(key Key) Path {
+	if path, ok := a.paths[key]; ok && (len(path) > 0 || a.graph.IsRoot(key)) {
+		return append(Path{}, path...)
+	}
+	return a.ancestors(key)
+}
+
+// ancestors follows the first parent of key upwards, until it finds an
+// IngressRoute with no parents or one it's already seen.
+func (a *analysis) ancestors(key Key) Path {
+
+	var path Path
+	seen := map[Key]bool{key: true}
+	for {
+		parents := a.graph.Parents[key]
+		if len(parents) == 0 {
+			break
+		}
+		edge := parents[0]
+		if seen[edge.Parent] {
+			break
+		}
+		seen[edge.Parent] = true
+		path = append(Path{edge}, path...)
+		key = edge.Parent
+	}
+	return path
+}
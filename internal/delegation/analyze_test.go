@@ -0,0 +1,114 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delegation
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+)
+
+func TestAnalyze(t *testing.T) {
+
+	tests := map[string]struct {
+		irs  []*irv1beta1.IngressRoute
+		want []string
+	}{
+		"valid tree": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "foo")),
+				ingressRoute("default", "foo", false, delegateRoute("/foo/bar", "", "bar")),
+				ingressRoute("default", "bar", false),
+			},
+			want: nil,
+		},
+		"cycle": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "foo")),
+				ingressRoute("default", "foo", false, delegateRoute("/foo/bar", "", "bar")),
+				ingressRoute("default", "bar", false, delegateRoute("/foo/bar/baz", "", "foo")),
+			},
+			want: []string{
+				"Delegation cycle found: default/root -> /foo -> default/foo -> /foo/bar -> default/bar -> /foo/bar/baz -> default/foo. Contour won't serve any of the routes in it.",
+				"IngressRoute default/foo is delegated to at conflicting prefixes: default/root -> /foo -> default/foo; default/root -> /foo -> default/foo -> /foo/bar -> default/bar -> /foo/bar/baz -> default/foo. HTTPProxy prefix conditions should not include the include prefix, so only one can be trimmed.",
+			},
+		},
+		"delegation to self": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "root")),
+			},
+			want: []string{
+				"Delegation cycle found: default/root -> /foo -> default/root. Contour won't serve any of the routes in it.",
+			},
+		},
+		"dangling delegate": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "foo")),
+				ingressRoute("default", "foo", false, delegateRoute("/foo/bar", "other", "bar")),
+			},
+			want: []string{
+				"Delegation to other/bar, which is not in the input: default/root -> /foo -> default/foo -> /foo/bar -> other/bar. Please check it exists.",
+			},
+		},
+		"unreachable": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "orphan", false),
+			},
+			want: []string{
+				"Nonroot IngressRoute default/orphan is not delegated to by any IngressRoute in the input. Please check its parent exists.",
+			},
+		},
+		"unreachable with parents": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "foo", false, delegateRoute("/foo/bar", "", "bar")),
+				ingressRoute("default", "bar", false),
+			},
+			want: []string{
+				"Nonroot IngressRoute default/foo is not delegated to by any IngressRoute in the input. Please check its parent exists.",
+				"Nonroot IngressRoute default/bar is not reachable from any root IngressRoute in the input: default/foo -> /foo/bar -> default/bar. Please check its parents exist.",
+			},
+		},
+		"conflicting prefixes": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "child")),
+				ingressRoute("default", "root2", true, delegateRoute("/bar", "", "child")),
+				ingressRoute("default", "child", false),
+			},
+			want: []string{
+				"IngressRoute default/child is delegated to at conflicting prefixes: default/root -> /foo -> default/child; default/root2 -> /bar -> default/child. HTTPProxy prefix conditions should not include the include prefix, so only one can be trimmed.",
+			},
+		},
+		"same prefix from two parents": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "child")),
+				ingressRoute("default", "root2", true, delegateRoute("/foo", "", "child")),
+				ingressRoute("default", "child", false),
+			},
+			want: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, finding := range Analyze(Build(tc.irs)) {
+				got = append(got, finding.String())
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
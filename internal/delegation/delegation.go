@@ -0,0 +1,152 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package delegation builds the delegation graph of a set of IngressRoute objects
+package delegation
+
+import (
+	"fmt"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+)
+
+// Key identifies an IngressRoute in the graph.
+type Key struct {
+	Namespace string
+	Name      string
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("%s/%s", k.Namespace, k.Name)
+}
+
+// KeyOf returns the Key for an IngressRoute.
+func KeyOf(ir *irv1beta1.IngressRoute) Key {
+	return Key{
+		Namespace: ir.ObjectMeta.Namespace,
+		Name:      ir.ObjectMeta.Name,
+	}
+}
+
+// Edge is a delegation from a parent IngressRoute to a child.
+type Edge struct {
+	Parent Key
+	Child  Key
+	// Prefix is the match of the delegating route.
+	// It's empty for tcpproxy delegation.
+	Prefix string
+	// TCPProxy is true if the delegation is from a tcpproxy.
+	TCPProxy bool
+}
+
+// Graph is the delegation graph of a set of IngressRoutes.
+// The child of an Edge may not be in Nodes, if it wasn't in the set.
+type Graph struct {
+	Nodes map[Key]*irv1beta1.IngressRoute
+	// Keys holds the Key of each IngressRoute, in the order they were supplied.
+	Keys     []Key
+	Children map[Key][]Edge
+	Parents  map[Key][]Edge
+}
+
+// Build builds the delegation graph for a set of IngressRoutes.
+func Build(irs []*irv1beta1.IngressRoute) *Graph {
+
+	g := &Graph{
+		Nodes:    make(map[Key]*irv1beta1.IngressRoute),
+		Children: make(map[Key][]Edge),
+		Parents:  make(map[Key][]Edge),
+	}
+
+	for _, ir := range irs {
+		key := KeyOf(ir)
+		if _, ok := g.Nodes[key]; !ok {
+			g.Keys = append(g.Keys, key)
+		}
+		g.Nodes[key] = ir
+	}
+
+	for _, key := range g.Keys {
+		ir := g.Nodes[key]
+		for _, route := range ir.Spec.Routes {
+			if route.Delegate == nil {
+				continue
+			}
+			g.addEdge(Edge{
+				Parent: key,
+				Child:  delegateKey(key, route.Delegate),
+				Prefix: route.Match,
+			})
+		}
+		if ir.Spec.TCPProxy != nil && ir.Spec.TCPProxy.Delegate != nil {
+			g.addEdge(Edge{
+				Parent:   key,
+				Child:    delegateKey(key, ir.Spec.TCPProxy.Delegate),
+				TCPProxy: true,
+			})
+		}
+	}
+
+	return g
+}
+
+func (g *Graph) addEdge(edge Edge) {
+	g.Children[edge.Parent] = append(g.Children[edge.Parent], edge)
+	g.Parents[edge.Child] = append(g.Parents[edge.Child], edge)
+}
+
+// delegateKey returns the Key a Delegate refers to. As in Contour, a Delegate
+// without a namespace refers to the namespace of the delegating IngressRoute.
+func delegateKey(parent Key, delegate *irv1beta1.Delegate) Key {
+	namespace := delegate.Namespace
+	if namespace == "" {
+		namespace = parent.Namespace
+	}
+	return Key{
+		Namespace: namespace,
+		Name:      delegate.Name,
+	}
+}
+
+// IsRoot returns true if the IngressRoute for key is in the graph and is a root.
+func (g *Graph) IsRoot(key Key) bool {
+	ir, ok := g.Nodes[key]
+	return ok && ir.Spec.VirtualHost != nil
+}
+
+// IncludePrefixes returns the distinct prefixes the IngressRoute for key
+// is delegated to at by route delegation, in the order they were found.
+func (g *Graph) IncludePrefixes(key Key) []string {
+
+	var prefixes []string
+	seen := make(map[string]bool)
+	for _, edge := range g.Parents[key] {
+		if edge.TCPProxy || seen[edge.Prefix] {
+			continue
+		}
+		seen[edge.Prefix] = true
+		prefixes = append(prefixes, edge.Prefix)
+	}
+	return prefixes
+}
+
+// IsTCPProxyDelegated returns true if the tcpproxy of any IngressRoute in the
+// graph delegates to the IngressRoute for key.
+func (g *Graph) IsTCPProxyDelegated(key Key) bool {
+	for _, edge := range g.Parents[key] {
+		if edge.TCPProxy {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delegation
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func ingressRoute(namespace, name string, root bool, routes ...irv1beta1.Route) *irv1beta1.IngressRoute {
+	ir := &irv1beta1.IngressRoute{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Spec: irv1beta1.IngressRouteSpec{
+			Routes: routes,
+		},
+	}
+	if root {
+		ir.Spec.VirtualHost = &hpv1.VirtualHost{
+			Fqdn: name + ".bar.com",
+		}
+	}
+	return ir
+}
+
+func delegateRoute(match, namespace, name string) irv1beta1.Route {
+	return irv1beta1.Route{
+		Match: match,
+		Delegate: &irv1beta1.Delegate{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+
+	root := ingressRoute("default", "root", true,
+		delegateRoute("/foo", "", "foo"),
+		delegateRoute("/bar", "other", "bar"),
+	)
+	tcproot := ingressRoute("default", "tcproot", true)
+	tcproot.Spec.TCPProxy = &irv1beta1.TCPProxy{
+		Delegate: &irv1beta1.Delegate{
+			Name: "tcp",
+		},
+	}
+	foo := ingressRoute("default", "foo", false)
+
+	g := Build([]*irv1beta1.IngressRoute{root, tcproot, foo})
+
+	wantKeys := []Key{
+		{Namespace: "default", Name: "root"},
+		{Namespace: "default", Name: "tcproot"},
+		{Namespace: "default", Name: "foo"},
+	}
+	if diff := cmp.Diff(g.Keys, wantKeys); diff != "" {
+		t.Fatal(diff)
+	}
+
+	wantChildren := []Edge{
+		{Parent: Key{"default", "root"}, Child: Key{"default", "foo"}, Prefix: "/foo"},
+		{Parent: Key{"default", "root"}, Child: Key{"other", "bar"}, Prefix: "/bar"},
+	}
+	if diff := cmp.Diff(g.Children[Key{"default", "root"}], wantChildren); diff != "" {
+		t.Fatal(diff)
+	}
+
+	wantTCPParents := []Edge{
+		{Parent: Key{"default", "tcproot"}, Child: Key{"default", "tcp"}, TCPProxy: true},
+	}
+	if diff := cmp.Diff(g.Parents[Key{"default", "tcp"}], wantTCPParents); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if !g.IsRoot(Key{"default", "root"}) {
+		t.Fatal("expected default/root to be a root")
+	}
+	if g.IsRoot(Key{"default", "foo"}) {
+		t.Fatal("expected default/foo not to be a root")
+	}
+	if g.IsRoot(Key{"other", "bar"}) {
+		t.Fatal("expected missing other/bar not to be a root")
+	}
+}
+
+func TestIncludePrefixes(t *testing.T) {
+
+	tests := map[string]struct {
+		irs  []*irv1beta1.IngressRoute
+		want []string
+	}{
+		"no parents": {
+			irs:  []*irv1beta1.IngressRoute{ingressRoute("default", "child", false)},
+			want: nil,
+		},
+		"one parent": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "child")),
+			},
+			want: []string{"/foo"},
+		},
+		"two parents, same prefix": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "child")),
+				ingressRoute("default", "root2", true, delegateRoute("/foo", "default", "child")),
+			},
+			want: []string{"/foo"},
+		},
+		"two parents, different prefixes": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "child")),
+				ingressRoute("default", "root2", true, delegateRoute("/bar", "", "child")),
+			},
+			want: []string{"/foo", "/bar"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Build(tc.irs).IncludePrefixes(Key{Namespace: "default", Name: "child"})
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestIsTCPProxyDelegated(t *testing.T) {
+
+	tcpproxyRoot := func(namespace, name string) *irv1beta1.IngressRoute {
+		ir := ingressRoute("default", "root", true)
+		ir.Spec.TCPProxy = &irv1beta1.TCPProxy{Delegate: &irv1beta1.Delegate{Namespace: namespace, Name: name}}
+		return ir
+	}
+
+	tests := map[string]struct {
+		irs  []*irv1beta1.IngressRoute
+		want bool
+	}{
+		"no parents": {
+			irs:  []*irv1beta1.IngressRoute{ingressRoute("default", "child", false)},
+			want: false,
+		},
+		"route delegation": {
+			irs: []*irv1beta1.IngressRoute{
+				ingressRoute("default", "root", true, delegateRoute("/foo", "", "child")),
+			},
+			want: false,
+		},
+		"tcpproxy delegation": {
+			irs:  []*irv1beta1.IngressRoute{tcpproxyRoot("", "child")},
+			want: true,
+		},
+		"tcpproxy delegation to another namespace": {
+			irs:  []*irv1beta1.IngressRoute{tcpproxyRoot("other", "child")},
+			want: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Build(tc.irs).IsTCPProxyDelegated(Key{Namespace: "default", Name: "child"})
+			if got != tc.want {
+				t.Fatalf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,650 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gatewayapi translates IngressRoute objects to Gateway API ones
+package gatewayapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	"github.com/projectcontour/ir2proxy/internal/delegation"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// GatewayClassName is the GatewayClass of the Gateway the routes attach to.
+const GatewayClassName = "contour"
+
+// httpListener is the name of the Gateway listener for plain HTTP.
+const httpListener = "http"
+
+// Translation is the result of translating one IngressRoute in a set.
+type Translation struct {
+	IngressRoute *irv1beta1.IngressRoute
+	// Objects are the routes the IngressRoute is translated to.
+	Objects  []interface{}
+	Warnings []warning.Warning
+	Err      error
+}
+
+// IngressRoutesToGatewayAPI translates a set of IngressRoute objects to Gateway API routes,
+// returning a Translation for each, in the same order.
+// The routes of root IngressRoutes attach to the named Gateway, and the routes of nonroot
+// IngressRoutes attach to the listeners of the roots that delegate to them. The Gateway, with
+// a listener for each TLS virtual host, is returned with the ReferenceGrants for its TLS
+// secrets, since they're shared by all the IngressRoutes.
+func IngressRoutesToGatewayAPI(irs []*irv1beta1.IngressRoute, gateway types.NamespacedName) ([]interface{}, []Translation) {
+
+	b := &builder{
+		gatewayName: gateway,
+		graph:       delegation.Build(irs),
+		gateway: &Gateway{
+			TypeMeta: v1.TypeMeta{
+				Kind:       "Gateway",
+				APIVersion: VersionV1,
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Name:      gateway.Name,
+				Namespace: gateway.Namespace,
+			},
+			Spec: GatewaySpec{
+				GatewayClassName: GatewayClassName,
+				Listeners: []Listener{
+					{
+						Name:          httpListener,
+						Port:          80,
+						Protocol:      "HTTP",
+						AllowedRoutes: allowedRoutes(),
+					},
+				},
+			},
+		},
+		seenGrants: make(map[types.NamespacedName]bool),
+	}
+
+	translations := make([]Translation, 0, len(irs))
+	for _, ir := range irs {
+		t := &translation{builder: b, ir: ir}
+		err := t.translate()
+		if err != nil {
+			translations = append(translations, Translation{IngressRoute: ir, Err: err})
+			continue
+		}
+		// Only add the shared objects of IngressRoutes that translated.
+		for _, listener := range t.listeners {
+			b.addListener(listener)
+		}
+		for _, grant := range t.grants {
+			b.addGrant(grant)
+		}
+		translations = append(translations, Translation{
+			IngressRoute: ir,
+			Objects:      t.objects,
+			Warnings:     warning.WithObject(t.warnings, warning.Object{Kind: "IngressRoute", Namespace: ir.Namespace, Name: ir.Name}),
+		})
+	}
+
+	shared := []interface{}{b.gateway}
+	for _, grant := range b.grants {
+		shared = append(shared, grant)
+	}
+	return shared, translations
+}
+
+// builder holds the objects shared by the translations of a set of IngressRoutes.
+type builder struct {
+	gatewayName types.NamespacedName
+	graph       *delegation.Graph
+	gateway     *Gateway
+	grants      []*ReferenceGrant
+	seenGrants  map[types.NamespacedName]bool
+}
+
+func (b *builder) addListener(listener Listener) {
+	for _, existing := range b.gateway.Spec.Listeners {
+		if existing.Name == listener.Name {
+			return
+		}
+	}
+	b.gateway.Spec.Listeners = append(b.gateway.Spec.Listeners, listener)
+}
+
+func (b *builder) addGrant(grant *ReferenceGrant) {
+	key := types.NamespacedName{Namespace: grant.Namespace, Name: grant.Name}
+	if b.seenGrants[key] {
+		return
+	}
+	b.seenGrants[key] = true
+	b.grants = append(b.grants, grant)
+}
+
+// translation is the translation of a single IngressRoute.
+type translation struct {
+	*builder
+	ir        *irv1beta1.IngressRoute
+	objects   []interface{}
+	listeners []Listener
+	grants    []*ReferenceGrant
+	warnings  []warning.Warning
+}
+
+// warn adds a warning about the field at path.
+func (t *translation) warn(severity warning.Severity, code warning.Code, path string, format string, args ...interface{}) {
+	t.warnings = append(t.warnings, warning.New(severity, code, path, format, args...))
+}
+
+func (t *translation) translate() error {
+
+	ir := t.ir
+
+	if ir.Spec.TCPProxy != nil && ir.Spec.VirtualHost == nil {
+		if !t.graph.IsTCPProxyDelegated(delegation.KeyOf(ir)) {
+			return errors.New("invalid IngressRoute: tcpproxy must be in a root IngressRoute, or one that a tcpproxy delegates to")
+		}
+		// The tcpproxy that delegates here has already been warned about.
+		t.warn(warning.SeverityWarning, warning.GatewayTCPProxyDelegate, ".spec.tcpproxy", "tcpproxy could not be translated, Gateway API can't delegate TCP or TLS routes. Please move these services into the tcpproxy that delegates to this IngressRoute.")
+	}
+
+	if vh := ir.Spec.VirtualHost; vh != nil {
+		if vh.TLS != nil && vh.TLS.MinimumProtocolVersion != "" {
+			t.warn(warning.SeverityWarning, warning.GatewayTLSMinimumVersion, ".spec.virtualhost.tls.minimumProtocolVersion", "minimumProtocolVersion %s on %s could not be applied, Gateway API has no minimum TLS version. Please check if it's needed.", vh.TLS.MinimumProtocolVersion, vh.Fqdn)
+		}
+		switch {
+		case ir.Spec.TCPProxy != nil:
+			t.translateTCPProxy()
+		case vh.TLS != nil && vh.TLS.SecretName != "":
+			t.listeners = append(t.listeners, Listener{
+				Name:     rootSection(ir),
+				Hostname: vh.Fqdn,
+				Port:     443,
+				Protocol: "HTTPS",
+				TLS: &GatewayTLSConfig{
+					Mode:            TLSModeTerminate,
+					CertificateRefs: []SecretObjectReference{t.certificateRef(vh.TLS.SecretName)},
+				},
+				AllowedRoutes: allowedRoutes(),
+			})
+		case vh.TLS != nil:
+			t.warn(warning.SeverityInfo, warning.GatewayTLSPassthrough, ".spec.virtualhost.tls.passthrough", "tls passthrough on %s has no effect without a tcpproxy, discarding. Please check if it's needed.", vh.Fqdn)
+		}
+	}
+
+	rules, httpRules := t.translateRoutes()
+
+	// IngressRoute matches are absolute, so the HTTPRoutes of a nonroot
+	// IngressRoute attach to the listeners of the roots that delegate to it,
+	// with their hostnames. HTTPRoute hostnames apply to every listener the
+	// route attaches to, so the roots with and without TLS need separate ones.
+	var secure, plain []*irv1beta1.IngressRoute
+	for _, root := range t.roots() {
+		if rootSection(root) == httpListener {
+			plain = append(plain, root)
+		} else {
+			secure = append(secure, root)
+		}
+	}
+	if ir.Spec.VirtualHost == nil && len(secure)+len(plain) == 0 && len(ir.Spec.Routes) > 0 {
+		t.warn(warning.SeverityWarning, warning.GatewayNoParent, "", "No root IngressRoute in the input delegates to this one, so its HTTPRoute has no parentRefs. Please translate it together with the root IngressRoute that delegates to it.")
+	}
+
+	// The other HTTPRoutes of a TLS virtual host are named after the
+	// IngressRoute, so they can clash with those of another IngressRoute.
+	if len(rules) > 0 && len(secure) > 0 {
+		names := []string{ir.Name + "-http"}
+		if len(plain) > 0 {
+			names = append(names, ir.Name+"-plain")
+		}
+		for _, name := range names {
+			if _, ok := t.graph.Nodes[delegation.Key{Namespace: ir.Namespace, Name: name}]; ok {
+				return fmt.Errorf("can't translate to an HTTPRoute named %s, since an IngressRoute in the input has the same name", name)
+			}
+		}
+	}
+
+	name := ir.Name
+	if len(secure) > 0 {
+		var parentRefs []ParentReference
+		for _, root := range secure {
+			parentRefs = append(parentRefs, t.gatewayRef(rootSection(root)))
+		}
+		if len(rules) > 0 {
+			t.addHTTPRoute(name, parentRefs, hostnames(secure), rules)
+			t.addHTTPRoute(ir.Name+"-http", []ParentReference{t.gatewayRef(httpListener)}, hostnames(secure), httpRules)
+		}
+		name = ir.Name + "-plain"
+	}
+	if len(secure) == 0 || len(plain) > 0 {
+		var parentRefs []ParentReference
+		if len(plain) > 0 {
+			parentRefs = []ParentReference{t.gatewayRef(httpListener)}
+		}
+		if len(rules) > 0 {
+			t.addHTTPRoute(name, parentRefs, hostnames(plain), rules)
+		}
+	}
+
+	return nil
+}
+
+// translateRoutes translates the routes of the IngressRoute to rules. It also
+// returns the rules for plain HTTP requests to a TLS virtual host, which, as
+// in HTTPProxy, redirect to HTTPS, except for routes that permit insecure
+// requests.
+func (t *translation) translateRoutes() ([]HTTPRouteRule, []HTTPRouteRule) {
+
+	// As in Contour, the routes of a nonroot IngressRoute must be under the
+	// prefix it's delegated at, and the first one that isn't stops the rest
+	// from being served.
+	var prefixes []string
+	if t.ir.Spec.VirtualHost == nil {
+		prefixes = t.graph.IncludePrefixes(delegation.KeyOf(t.ir))
+	}
+
+	var rules, httpRules []HTTPRouteRule
+	for index, irRoute := range t.ir.Spec.Routes {
+		path := fmt.Sprintf(".spec.routes[%d]", index)
+		if irRoute.Delegate != nil {
+			t.checkDelegateRoute(irRoute, path)
+			continue
+		}
+		if !matchesAnyPrefix(irRoute.Match, prefixes) {
+			t.warn(warning.SeverityWarning, warning.GatewayDelegateOutsidePrefix, path+".match", "Match %s is outside the path %s this IngressRoute is delegated at, so Contour ignores this route and the routes after it, discarding. Please check this value is correct.", irRoute.Match, strings.Join(prefixes, ", "))
+			break
+		}
+		rule := t.translateRoute(irRoute, path)
+		rules = append(rules, rule)
+		if irRoute.PermitInsecure {
+			httpRules = append(httpRules, rule)
+			continue
+		}
+		httpRules = append(httpRules, HTTPRouteRule{
+			Matches: rule.Matches,
+			Filters: []HTTPRouteFilter{
+				{
+					Type: FilterRequestRedirect,
+					RequestRedirect: &HTTPRequestRedirectFilter{
+						Scheme:     "https",
+						StatusCode: 301,
+					},
+				},
+			},
+		})
+	}
+	return rules, httpRules
+}
+
+// translateRoute translates the route at path in the IngressRoute.
+func (t *translation) translateRoute(irRoute irv1beta1.Route, path string) HTTPRouteRule {
+
+	rule := HTTPRouteRule{
+		Matches: t.pathPrefix(irRoute.Match, path),
+	}
+
+	if irRoute.PrefixRewrite != "" {
+		rule.Filters = append(rule.Filters, HTTPRouteFilter{
+			Type: FilterURLRewrite,
+			URLRewrite: &HTTPURLRewriteFilter{
+				Path: &HTTPPathModifier{
+					Type:               PathModifierReplacePrefixMatch,
+					ReplacePrefixMatch: irRoute.PrefixRewrite,
+				},
+			},
+		})
+	}
+
+	if irRoute.TimeoutPolicy != nil && irRoute.TimeoutPolicy.Request != "" {
+		timeout, err := gatewayDuration(irRoute.TimeoutPolicy.Request)
+		if err != nil {
+			t.warn(warning.SeverityWarning, warning.GatewayTimeoutInvalid, path+".timeoutPolicy.request", "Request timeout %s on route %s could not be applied, %s, discarding. Please check the timeout is correct.", irRoute.TimeoutPolicy.Request, irRoute.Match, err)
+		} else {
+			rule.Timeouts = &HTTPRouteTimeouts{Request: timeout}
+		}
+	}
+
+	if irRoute.RetryPolicy != nil {
+		t.warn(warning.SeverityWarning, warning.GatewayRetryPolicy, path+".retryPolicy", "retryPolicy on route %s could not be applied, Gateway API has no retry policy. Please check if it's needed.", irRoute.Match)
+	}
+	if irRoute.EnableWebsockets {
+		t.warn(warning.SeverityWarning, warning.GatewayWebsockets, path+".enableWebsockets", "enableWebsockets on route %s could not be applied, Gateway API has no websocket setting. Please check your Gateway implementation supports websockets.", irRoute.Match)
+	}
+
+	rule.BackendRefs = t.backendRefs(irRoute.Services, path+".services")
+
+	return rule
+}
+
+// checkDelegateRoute warns about the flags on a delegating route. As for
+// HTTPProxy includes, they had no effect on the delegated routes.
+func (t *translation) checkDelegateRoute(irRoute irv1beta1.Route, path string) {
+	if irRoute.EnableWebsockets {
+		t.warn(warning.SeverityWarning, warning.GatewayDelegateWebsockets, path+".enableWebsockets", "enableWebsockets on the route delegating %s to %s had no effect on the delegated routes, discarding. Please check your Gateway implementation supports websockets.", irRoute.Match, irRoute.Delegate.Name)
+	}
+	if irRoute.PermitInsecure {
+		t.warn(warning.SeverityWarning, warning.GatewayDelegatePermitInsecure, path+".permitInsecure", "permitInsecure on the route delegating %s to %s had no effect on the delegated routes, discarding. Set permitInsecure on the routes of the delegated IngressRoute instead.", irRoute.Match, irRoute.Delegate.Name)
+	}
+}
+
+// translateTCPProxy translates the tcpproxy of a root IngressRoute into a
+// TLSRoute for TLS passthrough, or a TCPRoute for terminated TLS.
+func (t *translation) translateTCPProxy() {
+
+	ir := t.ir
+	vh := ir.Spec.VirtualHost
+	tcpproxy := ir.Spec.TCPProxy
+
+	if tcpproxy.Delegate != nil {
+		t.warn(warning.SeverityWarning, warning.GatewayTCPProxyDelegate, ".spec.tcpproxy.delegate", "tcpproxy delegation to %s could not be translated, Gateway API can't delegate TCP or TLS routes. Please move the delegated services into this tcpproxy.", tcpproxy.Delegate.Name)
+		return
+	}
+	if vh.TLS == nil {
+		t.warn(warning.SeverityInfo, warning.GatewayTCPProxyNoTLS, ".spec.tcpproxy", "tcpproxy on %s has no effect without tls, discarding. Please check if it's needed.", vh.Fqdn)
+		return
+	}
+
+	section := "tls-" + vh.Fqdn
+	listener := Listener{
+		Name:     section,
+		Hostname: vh.Fqdn,
+		Port:     443,
+		Protocol: "TLS",
+	}
+
+	backendRefs := t.backendRefs(tcpproxy.Services, ".spec.tcpproxy.services")
+	parentRefs := CommonRouteSpec{ParentRefs: []ParentReference{t.gatewayRef(section)}}
+
+	if vh.TLS.SecretName == "" {
+		listener.TLS = &GatewayTLSConfig{Mode: TLSModePassthrough}
+		listener.AllowedRoutes = allowedRoutes("TLSRoute")
+		t.objects = append(t.objects, &TLSRoute{
+			TypeMeta:   v1.TypeMeta{Kind: "TLSRoute", APIVersion: VersionV1alpha2},
+			ObjectMeta: objectMeta(ir, ir.Name),
+			Spec: TLSRouteSpec{
+				CommonRouteSpec: parentRefs,
+				Hostnames:       []string{vh.Fqdn},
+				Rules:           []TLSRouteRule{{BackendRefs: backendRefs}},
+			},
+		})
+	} else {
+		listener.TLS = &GatewayTLSConfig{
+			Mode:            TLSModeTerminate,
+			CertificateRefs: []SecretObjectReference{t.certificateRef(vh.TLS.SecretName)},
+		}
+		listener.AllowedRoutes = allowedRoutes("TCPRoute")
+		t.objects = append(t.objects, &TCPRoute{
+			TypeMeta:   v1.TypeMeta{Kind: "TCPRoute", APIVersion: VersionV1alpha2},
+			ObjectMeta: objectMeta(ir, ir.Name),
+			Spec: TCPRouteSpec{
+				CommonRouteSpec: parentRefs,
+				Rules:           []TCPRouteRule{{BackendRefs: backendRefs}},
+			},
+		})
+	}
+
+	t.listeners = append(t.listeners, listener)
+}
+
+// backendRefs translates the services of a route or tcpproxy, at path.
+func (t *translation) backendRefs(services []irv1beta1.Service, path string) []BackendRef {
+
+	// Contour splits traffic evenly if no service has a weight, otherwise
+	// services without one get none. Gateway API gives them a weight of 1.
+	var weighted bool
+	for _, service := range services {
+		weighted = weighted || service.Weight > 0
+	}
+
+	var refs []BackendRef
+	for index, service := range services {
+		servicePath := fmt.Sprintf("%s[%d]", path, index)
+		port := int32(service.Port)
+		ref := BackendRef{
+			Name: service.Name,
+			Port: &port,
+		}
+		if weighted {
+			weight := int32(service.Weight)
+			ref.Weight = &weight
+		}
+		refs = append(refs, ref)
+
+		if service.Strategy != "" {
+			t.warn(warning.SeverityWarning, warning.GatewayLBStrategy, servicePath+".strategy", "Strategy %s on Service %s could not be applied, Gateway API has no load balancing policy. Please check if it's needed.", service.Strategy, service.Name)
+		}
+		if service.HealthCheck != nil {
+			t.warn(warning.SeverityWarning, warning.GatewayHealthCheck, servicePath+".healthCheck", "A healthcheck on service %s could not be applied, Gateway API has no health checks. Please check if it's needed.", service.Name)
+		}
+		if service.UpstreamValidation != nil {
+			t.warn(warning.SeverityWarning, warning.GatewayUpstreamValidation, servicePath+".validation", "validation on service %s could not be applied, Gateway API needs a BackendTLSPolicy to validate upstreams. Please create one.", service.Name)
+		}
+	}
+	return refs
+}
+
+// roots returns the root IngressRoutes in the set that serve the routes of
+// this IngressRoute: itself if it's a root, otherwise the roots that delegate
+// to it, directly or through other nonroot IngressRoutes. As in Contour, the
+// delegation stops at a root.
+func (t *translation) roots() []*irv1beta1.IngressRoute {
+
+	if t.ir.Spec.VirtualHost != nil {
+		return []*irv1beta1.IngressRoute{t.ir}
+	}
+
+	var roots []*irv1beta1.IngressRoute
+	seen := make(map[delegation.Key]bool)
+	var walk func(key delegation.Key)
+	walk = func(key delegation.Key) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if t.graph.IsRoot(key) {
+			roots = append(roots, t.graph.Nodes[key])
+			return
+		}
+		for _, edge := range t.graph.Parents[key] {
+			if !edge.TCPProxy {
+				walk(edge.Parent)
+			}
+		}
+	}
+	walk(delegation.KeyOf(t.ir))
+	return roots
+}
+
+// rootSection returns the listener the routes of a root IngressRoute attach
+// to. As in Contour, the routes of an IngressRoute with a tcpproxy are only
+// served over plain HTTP.
+func rootSection(root *irv1beta1.IngressRoute) string {
+	vh := root.Spec.VirtualHost
+	if root.Spec.TCPProxy == nil && vh.TLS != nil && vh.TLS.SecretName != "" {
+		return "https-" + vh.Fqdn
+	}
+	return httpListener
+}
+
+// hostnames returns the fqdns of the root IngressRoutes, without duplicates.
+func hostnames(roots []*irv1beta1.IngressRoute) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		if fqdn := root.Spec.VirtualHost.Fqdn; !seen[fqdn] {
+			seen[fqdn] = true
+			names = append(names, fqdn)
+		}
+	}
+	return names
+}
+
+func (t *translation) addHTTPRoute(name string, parentRefs []ParentReference, hostnames []string, rules []HTTPRouteRule) {
+	t.objects = append(t.objects, &HTTPRoute{
+		TypeMeta:   v1.TypeMeta{Kind: "HTTPRoute", APIVersion: VersionV1},
+		ObjectMeta: objectMeta(t.ir, name),
+		Spec: HTTPRouteSpec{
+			CommonRouteSpec: CommonRouteSpec{ParentRefs: parentRefs},
+			Hostnames:       hostnames,
+			Rules:           rules,
+		},
+	})
+}
+
+func (t *translation) gatewayRef(section string) ParentReference {
+	ref := ParentReference{
+		Name:        t.gatewayName.Name,
+		SectionName: section,
+	}
+	if t.gatewayName.Namespace != t.ir.Namespace {
+		ref.Namespace = t.gatewayName.Namespace
+	}
+	return ref
+}
+
+// certificateRef returns a reference to a TLS secret, which can be in another
+// namespace like in IngressRoute. The Gateway needs a ReferenceGrant to use a
+// secret from another namespace.
+func (t *translation) certificateRef(secretName string) SecretObjectReference {
+
+	secret := types.NamespacedName{Namespace: t.ir.Namespace, Name: secretName}
+	if parts := strings.SplitN(secretName, "/", 2); len(parts) == 2 {
+		secret = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+
+	ref := SecretObjectReference{Name: secret.Name}
+	if secret.Namespace != t.gatewayName.Namespace {
+		ref.Namespace = secret.Namespace
+		t.grants = append(t.grants, referenceGrant(secret.Namespace, secret.Name+"-from-"+t.gatewayName.Namespace,
+			ReferenceGrantFrom{Group: GroupName, Kind: "Gateway", Namespace: t.gatewayName.Namespace},
+			ReferenceGrantTo{Group: "", Kind: "Secret", Name: secret.Name}))
+	}
+	return ref
+}
+
+// pathPrefix returns the match for the match of the route at path.
+func (t *translation) pathPrefix(match string, path string) []HTTPRouteMatch {
+	if match != "/" && !strings.HasSuffix(match, "/") {
+		t.warn(warning.SeverityWarning, warning.GatewayPathPrefix, path+".match", "Match %s is translated to a PathPrefix, which only matches whole path segments, so paths like %sfoo no longer match. Please check this value is correct.", match, match)
+	}
+	return pathPrefix(match)
+}
+
+// matchesAnyPrefix returns true if there are no prefixes, or match is under
+// one of them by whole path segments.
+func matchesAnyPrefix(match string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	if !strings.HasSuffix(match, "/") {
+		match += "/"
+	}
+	for _, prefix := range prefixes {
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		if strings.HasPrefix(match, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func pathPrefix(prefix string) []HTTPRouteMatch {
+	return []HTTPRouteMatch{
+		{
+			Path: &HTTPPathMatch{
+				Type:  PathMatchPathPrefix,
+				Value: prefix,
+			},
+		},
+	}
+}
+
+// allowedRoutes allows routes of the given kinds, or the default kinds for
+// the listener's protocol, from all namespaces to attach to a listener.
+func allowedRoutes(kinds ...string) *AllowedRoutes {
+	allowed := &AllowedRoutes{
+		Namespaces: &RouteNamespaces{From: "All"},
+	}
+	for _, kind := range kinds {
+		allowed.Kinds = append(allowed.Kinds, RouteGroupKind{Group: GroupName, Kind: kind})
+	}
+	return allowed
+}
+
+func referenceGrant(namespace, name string, from ReferenceGrantFrom, to ReferenceGrantTo) *ReferenceGrant {
+	return &ReferenceGrant{
+		TypeMeta: v1.TypeMeta{Kind: "ReferenceGrant", APIVersion: VersionV1beta1},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: ReferenceGrantSpec{
+			From: []ReferenceGrantFrom{from},
+			To:   []ReferenceGrantTo{to},
+		},
+	}
+}
+
+func objectMeta(ir *irv1beta1.IngressRoute, name string) v1.ObjectMeta {
+	return v1.ObjectMeta{
+		Name:        name,
+		Namespace:   ir.Namespace,
+		Labels:      ir.ObjectMeta.DeepCopy().GetLabels(),
+		Annotations: ir.ObjectMeta.DeepCopy().GetAnnotations(),
+	}
+}
+
+// gatewayDuration converts an IngressRoute timeout into a Gateway API duration,
+// which only allows hours, minutes, seconds and milliseconds.
+func gatewayDuration(timeout string) (string, error) {
+
+	// A zero duration turns the timeout off.
+	if timeout == "infinity" {
+		return "0s", nil
+	}
+
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return "", errors.New("it is not a valid duration")
+	}
+	if d < 0 {
+		return "", errors.New("it is negative")
+	}
+	if d%time.Millisecond != 0 {
+		return "", errors.New("durations shorter than a millisecond can't be represented")
+	}
+	if d == 0 {
+		return "0s", nil
+	}
+
+	var duration strings.Builder
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+		{time.Millisecond, "ms"},
+	} {
+		if count := d / unit.size; count > 0 {
+			fmt.Fprintf(&duration, "%d%s", count, unit.name)
+			d -= count * unit.size
+		}
+	}
+	return duration.String(), nil
+}
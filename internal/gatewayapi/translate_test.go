@@ -0,0 +1,215 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewayapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/input"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var testGateway = types.NamespacedName{Namespace: "projectcontour", Name: "contour"}
+
+// TestIngressRoutesToGatewayAPI translates the IngressRoutes in each
+// testdata/<case>/input.yaml together, and checks the output against
+// output.yaml, and the warnings against errors.txt.
+func TestIngressRoutesToGatewayAPI(t *testing.T) {
+
+	testdataDirs, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range testdataDirs {
+		if !dir.IsDir() {
+			continue
+		}
+		t.Run(dir.Name(), func(t *testing.T) {
+			irs := readIngressRoutes(t, fmt.Sprintf("testdata/%s/input.yaml", dir.Name()))
+
+			shared, translations := IngressRoutesToGatewayAPI(irs, testGateway)
+
+			objects := shared
+			var warnings []string
+			for _, translation := range translations {
+				if translation.Err != nil {
+					t.Fatal(translation.Err)
+				}
+				objects = append(objects, translation.Objects...)
+				warnings = append(warnings, warning.Strings(translation.Warnings)...)
+			}
+
+			var output []byte
+			for _, obj := range objects {
+				objYAML, err := yaml.Marshal(obj)
+				if err != nil {
+					t.Fatal(err)
+				}
+				output = append(output, "---\n"...)
+				output = append(output, objYAML...)
+			}
+			output = bytes.ReplaceAll(output, []byte("  creationTimestamp: null\n"), []byte(""))
+
+			want, err := ioutil.ReadFile(fmt.Sprintf("testdata/%s/output.yaml", dir.Name()))
+			if err != nil {
+				t.Fatalf("testdata/%s/output.yaml must be present, %s", dir.Name(), err)
+			}
+			if diff := cmp.Diff(string(bytes.TrimSpace(output)), string(bytes.TrimSpace(want))); diff != "" {
+				t.Fatalf("Translation mismatch:\n%s", diff)
+			}
+
+			errordata, err := ioutil.ReadFile(fmt.Sprintf("testdata/%s/errors.txt", dir.Name()))
+			if err != nil {
+				t.Fatalf("testdata/%s/errors.txt must be present, %s", dir.Name(), err)
+			}
+			var wantWarnings []string
+			for _, warning := range strings.Split(string(errordata), "\n") {
+				if warning != "" {
+					wantWarnings = append(wantWarnings, warning)
+				}
+			}
+			if diff := cmp.Diff(warnings, wantWarnings); diff != "" {
+				t.Fatalf("Translation warnings mismatch:\n%s", diff)
+			}
+		})
+	}
+}
+
+func readIngressRoutes(t *testing.T, file string) []*irv1beta1.IngressRoute {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var irs []*irv1beta1.IngressRoute
+	documents := input.NewDocumentReader(bytes.NewReader(data))
+	for {
+		doc, err := documents.Read()
+		if err == io.EOF {
+			return irs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ir, err := k8sdecoder.DecodeIngressRoute(doc.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		irs = append(irs, ir)
+	}
+}
+
+func TestIngressRoutesToGatewayAPIErrors(t *testing.T) {
+
+	tcpproxy := &irv1beta1.IngressRoute{}
+	tcpproxy.Name = "tcpproxy"
+	tcpproxy.Namespace = "default"
+	tcpproxy.Spec.TCPProxy = &irv1beta1.TCPProxy{
+		Services: []irv1beta1.Service{{Name: "s1", Port: 80}},
+	}
+
+	tls := &irv1beta1.IngressRoute{}
+	tls.Name = "blog"
+	tls.Namespace = "default"
+	tls.Spec.VirtualHost = &hpv1.VirtualHost{
+		Fqdn: "blog.example.com",
+		TLS:  &hpv1.TLS{SecretName: "blog-tls"},
+	}
+	tls.Spec.Routes = []irv1beta1.Route{{
+		Match:    "/",
+		Services: []irv1beta1.Service{{Name: "blog", Port: 80}},
+	}}
+
+	clash := &irv1beta1.IngressRoute{}
+	clash.Name = "blog-http"
+	clash.Namespace = "default"
+	clash.Spec.VirtualHost = &hpv1.VirtualHost{Fqdn: "www.example.com"}
+
+	otherNamespace := clash.DeepCopy()
+	otherNamespace.Namespace = "other"
+
+	tests := map[string]struct {
+		irs  []*irv1beta1.IngressRoute
+		want string
+	}{
+		"tcpproxy in a nonroot IngressRoute": {
+			irs:  []*irv1beta1.IngressRoute{tcpproxy},
+			want: "invalid IngressRoute: tcpproxy must be in a root IngressRoute, or one that a tcpproxy delegates to",
+		},
+		"http route name clashes with another IngressRoute": {
+			irs:  []*irv1beta1.IngressRoute{tls, clash},
+			want: "can't translate to an HTTPRoute named blog-http, since an IngressRoute in the input has the same name",
+		},
+		"same name in another namespace": {
+			irs: []*irv1beta1.IngressRoute{tls, otherNamespace},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, translations := IngressRoutesToGatewayAPI(tc.irs, testGateway)
+			err := translations[0].Err
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.want {
+				t.Fatalf("want error %q, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestGatewayDuration(t *testing.T) {
+
+	tests := map[string]struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		"seconds":          {input: "10s", want: "10s"},
+		"minutes":          {input: "90s", want: "1m30s"},
+		"hours":            {input: "1h0m0.5s", want: "1h500ms"},
+		"infinity":         {input: "infinity", want: "0s"},
+		"zero":             {input: "0s", want: "0s"},
+		"microseconds":     {input: "1500us", wantErr: true},
+		"negative":         {input: "-1s", wantErr: true},
+		"invalid duration": {input: "ten seconds", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := gatewayDuration(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want error: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Fatalf("want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewayapi
+
+import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The types here are the subset of the Gateway API types that ir2proxy
+// outputs. They serialize the same way as the upstream types in
+// sigs.k8s.io/gateway-api, which need a newer Kubernetes client than this
+// module uses.
+
+// GroupName is the API group of the Gateway API types.
+const GroupName = "gateway.networking.k8s.io"
+
+// The API versions the types are output as.
+const (
+	VersionV1       = GroupName + "/v1"
+	VersionV1beta1  = GroupName + "/v1beta1"
+	VersionV1alpha2 = GroupName + "/v1alpha2"
+)
+
+// Gateway is a gateway.networking.k8s.io/v1 Gateway.
+type Gateway struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GatewaySpec `json:"spec"`
+}
+
+// GatewaySpec is the spec of a Gateway.
+type GatewaySpec struct {
+	GatewayClassName string     `json:"gatewayClassName"`
+	Listeners        []Listener `json:"listeners"`
+}
+
+// Listener is a port, protocol and hostname a Gateway accepts traffic on.
+type Listener struct {
+	Name          string            `json:"name"`
+	Hostname      string            `json:"hostname,omitempty"`
+	Port          int32             `json:"port"`
+	Protocol      string            `json:"protocol"`
+	TLS           *GatewayTLSConfig `json:"tls,omitempty"`
+	AllowedRoutes *AllowedRoutes    `json:"allowedRoutes,omitempty"`
+}
+
+// The TLS modes of a Listener.
+const (
+	TLSModeTerminate   = "Terminate"
+	TLSModePassthrough = "Passthrough"
+)
+
+// GatewayTLSConfig is the TLS configuration of a Listener.
+type GatewayTLSConfig struct {
+	Mode            string                  `json:"mode,omitempty"`
+	CertificateRefs []SecretObjectReference `json:"certificateRefs,omitempty"`
+}
+
+// SecretObjectReference refers to a Secret.
+type SecretObjectReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AllowedRoutes says which routes can attach to a Listener.
+type AllowedRoutes struct {
+	Namespaces *RouteNamespaces `json:"namespaces,omitempty"`
+	Kinds      []RouteGroupKind `json:"kinds,omitempty"`
+}
+
+// RouteNamespaces says which namespaces routes can attach to a Listener from.
+type RouteNamespaces struct {
+	From string `json:"from,omitempty"`
+}
+
+// RouteGroupKind is a kind of route.
+type RouteGroupKind struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind"`
+}
+
+// ParentReference refers to the Gateway, or the HTTPRoute, that a route attaches to.
+type ParentReference struct {
+	Group       string `json:"group,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// CommonRouteSpec holds the fields that all routes have.
+type CommonRouteSpec struct {
+	ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+}
+
+// BackendRef refers to a Service.
+type BackendRef struct {
+	Group     string `json:"group,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Port      *int32 `json:"port,omitempty"`
+	Weight    *int32 `json:"weight,omitempty"`
+}
+
+// HTTPRoute is a gateway.networking.k8s.io/v1 HTTPRoute.
+type HTTPRoute struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HTTPRouteSpec `json:"spec"`
+}
+
+// HTTPRouteSpec is the spec of an HTTPRoute.
+type HTTPRouteSpec struct {
+	CommonRouteSpec `json:",inline"`
+
+	Hostnames []string        `json:"hostnames,omitempty"`
+	Rules     []HTTPRouteRule `json:"rules,omitempty"`
+}
+
+// HTTPRouteRule is a rule of an HTTPRoute.
+type HTTPRouteRule struct {
+	Matches     []HTTPRouteMatch   `json:"matches,omitempty"`
+	Filters     []HTTPRouteFilter  `json:"filters,omitempty"`
+	BackendRefs []BackendRef       `json:"backendRefs,omitempty"`
+	Timeouts    *HTTPRouteTimeouts `json:"timeouts,omitempty"`
+}
+
+// PathMatchPathPrefix matches a prefix of the path, by whole path segments.
+const PathMatchPathPrefix = "PathPrefix"
+
+// HTTPRouteMatch is a match of an HTTPRouteRule.
+type HTTPRouteMatch struct {
+	Path *HTTPPathMatch `json:"path,omitempty"`
+}
+
+// HTTPPathMatch matches the path of a request.
+type HTTPPathMatch struct {
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// The types of HTTPRouteFilter.
+const (
+	FilterRequestRedirect = "RequestRedirect"
+	FilterURLRewrite      = "URLRewrite"
+)
+
+// HTTPRouteFilter changes a request or its response.
+type HTTPRouteFilter struct {
+	Type            string                     `json:"type"`
+	RequestRedirect *HTTPRequestRedirectFilter `json:"requestRedirect,omitempty"`
+	URLRewrite      *HTTPURLRewriteFilter      `json:"urlRewrite,omitempty"`
+}
+
+// HTTPRequestRedirectFilter redirects a request.
+type HTTPRequestRedirectFilter struct {
+	Scheme     string `json:"scheme,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+}
+
+// HTTPURLRewriteFilter rewrites the URL of a request.
+type HTTPURLRewriteFilter struct {
+	Path *HTTPPathModifier `json:"path,omitempty"`
+}
+
+// PathModifierReplacePrefixMatch replaces the matched prefix of the path.
+const PathModifierReplacePrefixMatch = "ReplacePrefixMatch"
+
+// HTTPPathModifier changes the path of a request.
+type HTTPPathModifier struct {
+	Type               string `json:"type"`
+	ReplacePrefixMatch string `json:"replacePrefixMatch,omitempty"`
+}
+
+// HTTPRouteTimeouts are the timeouts of an HTTPRouteRule.
+type HTTPRouteTimeouts struct {
+	Request string `json:"request,omitempty"`
+}
+
+// TLSRoute is a gateway.networking.k8s.io/v1alpha2 TLSRoute.
+type TLSRoute struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TLSRouteSpec `json:"spec"`
+}
+
+// TLSRouteSpec is the spec of a TLSRoute.
+type TLSRouteSpec struct {
+	CommonRouteSpec `json:",inline"`
+
+	Hostnames []string       `json:"hostnames,omitempty"`
+	Rules     []TLSRouteRule `json:"rules"`
+}
+
+// TLSRouteRule is a rule of a TLSRoute.
+type TLSRouteRule struct {
+	BackendRefs []BackendRef `json:"backendRefs"`
+}
+
+// TCPRoute is a gateway.networking.k8s.io/v1alpha2 TCPRoute.
+type TCPRoute struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TCPRouteSpec `json:"spec"`
+}
+
+// TCPRouteSpec is the spec of a TCPRoute.
+type TCPRouteSpec struct {
+	CommonRouteSpec `json:",inline"`
+
+	Rules []TCPRouteRule `json:"rules"`
+}
+
+// TCPRouteRule is a rule of a TCPRoute.
+type TCPRouteRule struct {
+	BackendRefs []BackendRef `json:"backendRefs"`
+}
+
+// ReferenceGrant is a gateway.networking.k8s.io/v1beta1 ReferenceGrant.
+// It allows objects in other namespaces to refer to objects in its namespace.
+type ReferenceGrant struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReferenceGrantSpec `json:"spec"`
+}
+
+// ReferenceGrantSpec is the spec of a ReferenceGrant.
+type ReferenceGrantSpec struct {
+	From []ReferenceGrantFrom `json:"from"`
+	To   []ReferenceGrantTo   `json:"to"`
+}
+
+// ReferenceGrantFrom is the kind and namespace of the objects that are allowed
+// to refer to the objects in a ReferenceGrant's namespace.
+type ReferenceGrantFrom struct {
+	Group     string `json:"group"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo is the objects in a ReferenceGrant's namespace that can be
+// referred to.
+type ReferenceGrantTo struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+}
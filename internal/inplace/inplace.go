@@ -0,0 +1,494 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inplace replaces objects in YAML documents, keeping their comments and formatting
+package inplace
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Replacement is an object to put in place of an object in a document.
+type Replacement struct {
+	// Item is the position of the object in the List or array it came from,
+	// or -1 if the document holds a single object.
+	Item int
+	// YAML is the object to put in its place.
+	YAML []byte
+	// Comment is added above the object, with each line starting with #.
+	Comment string
+}
+
+// Replace returns the YAML document data, with the objects in replacements
+// put in place of the ones that are there.
+// The node tree of the document is edited, rather than the objects being
+// marshaled again, so that comments, key order, styles and anchors are kept
+// for everything that's the same in the replacement. The comments on fields
+// that are dropped are moved to the next field that's kept.
+// The whitespace around the document is kept too, so that it can be put
+// straight back into the stream it came from.
+func Replace(data []byte, replacements []Replacement) ([]byte, error) {
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 {
+		return nil, fmt.Errorf("can't replace objects in an empty document")
+	}
+
+	for _, replacement := range replacements {
+		target, err := objectNode(doc.Content[0], replacement.Item)
+		if err != nil {
+			return nil, err
+		}
+
+		var object yaml.Node
+		if err := yaml.Unmarshal(replacement.YAML, &object); err != nil {
+			return nil, err
+		}
+		if object.Kind != yaml.DocumentNode || len(object.Content) != 1 {
+			return nil, fmt.Errorf("a replacement must be a single object")
+		}
+
+		merged := merge(target, object.Content[0])
+		if replacement.Comment != "" {
+			merged.HeadComment = joinComments(replacement.Comment, merged.HeadComment)
+		}
+		// Only the anchor on the object itself needs to be kept, since
+		// aliases to it are fixed below.
+		anchor := target.Anchor
+		*target = *merged
+		target.Anchor = anchor
+	}
+	expandDanglingAliases(&doc)
+	untagMergeKeys(&doc)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	indent, ok := indentOf(doc.Content[0])
+	if !ok {
+		indent = 2
+	}
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	// The encoder always ends with a single newline, so put back the
+	// whitespace that was around the original document.
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	leading := data[:len(data)-len(bytes.TrimLeft(data, " \t\r\n"))]
+	trailing := data[len(trimmed):]
+	out := append([]byte{}, leading...)
+	out = append(out, bytes.TrimRight(buf.Bytes(), "\n")...)
+	return append(out, trailing...), nil
+}
+
+// objectNode returns the node of the object at item in the root node of a
+// document.
+func objectNode(root *yaml.Node, item int) (*yaml.Node, error) {
+	if item < 0 {
+		return root, nil
+	}
+	items := root
+	if root.Kind == yaml.MappingNode {
+		items = nil
+		for index := 0; index+1 < len(root.Content); index += 2 {
+			if root.Content[index].Value == "items" {
+				items = root.Content[index+1]
+			}
+		}
+	}
+	if items == nil || items.Kind != yaml.SequenceNode || item >= len(items.Content) {
+		return nil, fmt.Errorf("can't find item %d in the document", item)
+	}
+	return items.Content[item], nil
+}
+
+// merge returns the new node, with the comments, key order and styles of
+// the old one wherever they still apply. Anything that's the same in both
+// is the old node, along with its anchors and aliases.
+func merge(old *yaml.Node, updated *yaml.Node) *yaml.Node {
+
+	if equal(old, updated) {
+		return old
+	}
+
+	resolved := resolve(old)
+	if resolved.Kind != updated.Kind {
+		copyComments(updated, old)
+		return updated
+	}
+
+	switch updated.Kind {
+	case yaml.MappingNode:
+		return mergeMapping(old, resolved, updated)
+	case yaml.SequenceNode:
+		return mergeSequence(old, resolved, updated)
+	}
+
+	// A string that's changed keeps its quoting.
+	if resolved.ShortTag() == "!!str" && updated.ShortTag() == "!!str" {
+		updated.Style = resolved.Style
+	}
+	copyComments(updated, old)
+	return updated
+}
+
+// mergeMapping merges two mappings. The keys that are in both keep their old
+// order, and new keys go before the next key that's in both, in the order of
+// the new mapping.
+func mergeMapping(old *yaml.Node, resolved *yaml.Node, updated *yaml.Node) *yaml.Node {
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: updated.Tag, Style: resolved.Style}
+	copyComments(merged, old)
+
+	oldPairs := pairs(resolved)
+	newPairs := pairs(updated)
+	oldIndex := make(map[string]int)
+	for index, pair := range oldPairs {
+		oldIndex[pair.key.Value] = index
+	}
+	newIndex := make(map[string]int)
+	for index, pair := range newPairs {
+		newIndex[pair.key.Value] = index
+	}
+
+	// orphans are the comments from old keys that aren't in the new
+	// mapping, which go on the next key. Comments for the first key go on
+	// the mapping instead, since the encoder puts the head comment of the
+	// first key of a sequence item after the "- ".
+	var orphans string
+	add := func(key *yaml.Node, value *yaml.Node) {
+		if orphans != "" {
+			if len(merged.Content) == 0 {
+				merged.HeadComment = joinComments(merged.HeadComment, orphans)
+			} else {
+				key.HeadComment = joinComments(orphans, key.HeadComment)
+			}
+			orphans = ""
+		}
+		merged.Content = append(merged.Content, key, value)
+	}
+
+	next := 0
+	for _, oldPair := range oldPairs {
+		index, ok := newIndex[oldPair.key.Value]
+		if !ok {
+			orphans = joinComments(orphans, comments(oldPair.key), comments(oldPair.value))
+			continue
+		}
+		// New keys that come before this one.
+		for ; next < index; next++ {
+			if _, ok := oldIndex[newPairs[next].key.Value]; !ok {
+				add(newPairs[next].key, newPairs[next].value)
+			}
+		}
+		next = index + 1
+		add(oldPair.key, merge(oldPair.value, newPairs[index].value))
+	}
+	for ; next < len(newPairs); next++ {
+		if _, ok := oldIndex[newPairs[next].key.Value]; !ok {
+			add(newPairs[next].key, newPairs[next].value)
+		}
+	}
+	// Comments left over go after the last key, since the encoder puts the
+	// foot comments of nested mappings after the key that holds them.
+	if orphans != "" && len(merged.Content) > 0 {
+		last := merged.Content[len(merged.Content)-2]
+		last.FootComment = joinComments(last.FootComment, orphans)
+	} else {
+		merged.FootComment = joinComments(orphans, merged.FootComment)
+	}
+
+	return merged
+}
+
+// mergeSequence merges two sequences. Each new item is merged with the most
+// similar old item that hasn't already been used, and the comments of old
+// items that aren't used go on the next item.
+func mergeSequence(old *yaml.Node, resolved *yaml.Node, updated *yaml.Node) *yaml.Node {
+
+	merged := &yaml.Node{Kind: yaml.SequenceNode, Tag: updated.Tag, Style: resolved.Style}
+	copyComments(merged, old)
+
+	matches := make([]int, len(updated.Content))
+	used := make(map[int]bool)
+	for newIndex, newItem := range updated.Content {
+		matches[newIndex] = -1
+		best := 0
+		for oldIndex, oldItem := range resolved.Content {
+			if used[oldIndex] {
+				continue
+			}
+			score := similarity(oldItem, newItem)
+			if equal(oldItem, newItem) {
+				// An exact match always wins.
+				score = int(^uint(0) >> 1)
+			}
+			if score > best {
+				matches[newIndex], best = oldIndex, score
+			}
+		}
+		if matches[newIndex] >= 0 {
+			used[matches[newIndex]] = true
+		}
+	}
+
+	// The comments of old items that aren't used go on the new item that's
+	// merged with the next old item that is.
+	orphans := make(map[int]string)
+	var pending string
+	for oldIndex, oldItem := range resolved.Content {
+		if !used[oldIndex] {
+			pending = joinComments(pending, comments(oldItem))
+			continue
+		}
+		if pending != "" {
+			orphans[oldIndex], pending = pending, ""
+		}
+	}
+
+	for newIndex, newItem := range updated.Content {
+		item := newItem
+		if oldIndex := matches[newIndex]; oldIndex >= 0 {
+			item = merge(resolved.Content[oldIndex], newItem)
+			if orphans[oldIndex] != "" {
+				item.HeadComment = joinComments(orphans[oldIndex], item.HeadComment)
+			}
+		}
+		merged.Content = append(merged.Content, item)
+	}
+	merged.FootComment = joinComments(pending, merged.FootComment)
+
+	return merged
+}
+
+// pair is a key and value in a mapping.
+type pair struct {
+	key   *yaml.Node
+	value *yaml.Node
+}
+
+// pairs returns the keys and values of a mapping. Keys merged in with <<
+// come after the mapping's own keys, as copies, since the nodes they're
+// copied from stay where they are.
+func pairs(mapping *yaml.Node) []pair {
+	var own, merged []pair
+	seen := make(map[string]bool)
+	for index := 0; index+1 < len(mapping.Content); index += 2 {
+		key, value := mapping.Content[index], mapping.Content[index+1]
+		if key.ShortTag() == "!!merge" {
+			sources := []*yaml.Node{value}
+			if resolve(value).Kind == yaml.SequenceNode {
+				sources = resolve(value).Content
+			}
+			for _, source := range sources {
+				for _, p := range pairs(resolve(source)) {
+					merged = append(merged, pair{key: expand(p.key), value: expand(p.value)})
+				}
+			}
+			continue
+		}
+		own = append(own, pair{key: key, value: value})
+		seen[key.Value] = true
+	}
+	for _, p := range merged {
+		if !seen[p.key.Value] {
+			own = append(own, p)
+			seen[p.key.Value] = true
+		}
+	}
+	return own
+}
+
+// resolve follows an alias to the node it refers to.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// equal returns true if two nodes have the same value, ignoring comments,
+// styles and anchors.
+func equal(a *yaml.Node, b *yaml.Node) bool {
+	a, b = resolve(a), resolve(b)
+	if a.Kind != b.Kind {
+		return false
+	}
+	switch a.Kind {
+	case yaml.ScalarNode:
+		return a.ShortTag() == b.ShortTag() && a.Value == b.Value
+	case yaml.MappingNode:
+		aPairs, bPairs := pairs(a), pairs(b)
+		if len(aPairs) != len(bPairs) {
+			return false
+		}
+		values := make(map[string]*yaml.Node)
+		for _, p := range aPairs {
+			values[p.key.Value] = p.value
+		}
+		for _, p := range bPairs {
+			value, ok := values[p.key.Value]
+			if !ok || !equal(value, p.value) {
+				return false
+			}
+		}
+		return true
+	default:
+		if len(a.Content) != len(b.Content) {
+			return false
+		}
+		for index := range a.Content {
+			if !equal(a.Content[index], b.Content[index]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// similarity counts the scalars that are the same in two nodes.
+func similarity(a *yaml.Node, b *yaml.Node) int {
+	a, b = resolve(a), resolve(b)
+	if a.Kind != b.Kind {
+		return 0
+	}
+	score := 0
+	switch a.Kind {
+	case yaml.ScalarNode:
+		if a.Value == b.Value {
+			score = 1
+		}
+	case yaml.MappingNode:
+		values := make(map[string]*yaml.Node)
+		for _, p := range pairs(a) {
+			values[p.key.Value] = p.value
+		}
+		for _, p := range pairs(b) {
+			if value, ok := values[p.key.Value]; ok {
+				score += similarity(value, p.value)
+			}
+		}
+	default:
+		for index := 0; index < len(a.Content) && index < len(b.Content); index++ {
+			score += similarity(a.Content[index], b.Content[index])
+		}
+	}
+	return score
+}
+
+// expand returns a copy of a node with its aliases replaced by copies of
+// what they refer to, and no anchors.
+func expand(node *yaml.Node) *yaml.Node {
+	copied := *resolve(node)
+	copied.Anchor = ""
+	copyComments(&copied, node)
+	copied.Content = nil
+	for _, child := range resolve(node).Content {
+		copied.Content = append(copied.Content, expand(child))
+	}
+	return &copied
+}
+
+// expandDanglingAliases replaces the aliases whose anchor is no longer in
+// the document before them with a copy of what they referred to.
+func expandDanglingAliases(doc *yaml.Node) {
+	anchors := make(map[*yaml.Node]bool)
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Anchor != "" {
+			anchors[node] = true
+		}
+		for index, child := range node.Content {
+			if child.Kind == yaml.AliasNode && !anchors[child.Alias] {
+				node.Content[index] = expand(child)
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(doc)
+}
+
+// untagMergeKeys clears the tag of << keys, which the encoder would
+// otherwise write out as !!merge.
+func untagMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!merge" {
+		node.Tag = ""
+	}
+	if node.Kind == yaml.AliasNode {
+		return
+	}
+	for _, child := range node.Content {
+		untagMergeKeys(child)
+	}
+}
+
+// copyComments copies the comments of src to dst.
+func copyComments(dst *yaml.Node, src *yaml.Node) {
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+}
+
+// comments returns all the comments in a node and its children.
+func comments(node *yaml.Node) string {
+	all := []string{node.HeadComment, node.LineComment}
+	if node.Kind != yaml.AliasNode {
+		for _, child := range node.Content {
+			all = append(all, comments(child))
+		}
+	}
+	all = append(all, node.FootComment)
+	return joinComments(all...)
+}
+
+// joinComments joins the comments that aren't empty, one per line.
+func joinComments(comments ...string) string {
+	var lines []string
+	for _, comment := range comments {
+		if comment != "" {
+			lines = append(lines, comment)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentOf returns the number of spaces that the first nested block mapping
+// in a node is indented by, and false if there isn't one.
+func indentOf(node *yaml.Node) (int, bool) {
+	if node.Kind == yaml.MappingNode && node.Style&yaml.FlowStyle == 0 {
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			key, value := node.Content[index], node.Content[index+1]
+			if value.Kind == yaml.MappingNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0 && value.Column > key.Column {
+				return value.Column - key.Column, true
+			}
+		}
+	}
+	for _, child := range node.Content {
+		if indent, ok := indentOf(child); ok {
+			return indent, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,304 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inplace
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReplace(t *testing.T) {
+
+	tests := map[string]struct {
+		data         string
+		replacements []Replacement
+		want         string
+		wantErr      bool
+	}{
+		"comments and key order are kept": {
+			data: `
+# The blog.
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  namespace: default # line comment
+  name: blog
+spec:
+  virtualhost:
+    fqdn: "blog.example.com"
+  routes:
+    # The main route.
+    - match: /
+      services:
+        - name: blog
+          port: 80
+`,
+			replacements: []Replacement{{
+				Item: -1,
+				YAML: []byte(`apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: blog
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: blog.example.com
+  routes:
+  - conditions:
+    - prefix: /
+    services:
+    - name: blog
+      port: 80
+`),
+			}},
+			want: `
+# The blog.
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  namespace: default # line comment
+  name: blog
+spec:
+  virtualhost:
+    fqdn: "blog.example.com"
+  routes:
+    # The main route.
+    - conditions:
+        - prefix: /
+      services:
+        - name: blog
+          port: 80
+`,
+		},
+		"comments on dropped fields are moved": {
+			data: `apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: blog
+spec:
+  routes:
+    - match: /
+      services:
+        - name: blog
+          # Not supported.
+          strategy: Random
+          port: 80
+        - name: other
+          port: 80
+          strategy: Random # dropped
+`,
+			replacements: []Replacement{{
+				Item: -1,
+				YAML: []byte(`apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: blog
+spec:
+  routes:
+  - services:
+    - name: blog
+      port: 80
+    - name: other
+      port: 80
+`),
+			}},
+			want: `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: blog
+spec:
+  routes:
+    - services:
+        - name: blog
+          # Not supported.
+          port: 80
+        - name: other
+          port: 80
+          # dropped
+`,
+		},
+		"comments on a dropped first key stay above the item": {
+			data: `kind: IngressRoute
+spec:
+  routes:
+    # The main route.
+    - match: /x   # trailing
+      services:
+        - name: blog
+          port: 80
+`,
+			replacements: []Replacement{{
+				Item: -1,
+				YAML: []byte(`kind: HTTPProxy
+spec:
+  routes:
+  - conditions:
+    - prefix: /x
+    services:
+    - name: blog
+      port: 80
+`),
+			}},
+			want: `kind: HTTPProxy
+spec:
+  routes:
+    # The main route.
+    # trailing
+    - conditions:
+        - prefix: /x
+      services:
+        - name: blog
+          port: 80
+`,
+		},
+		"warnings are added as a comment": {
+			data: `apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: blog
+`,
+			replacements: []Replacement{{
+				Item:    -1,
+				YAML:    []byte("apiVersion: projectcontour.io/v1\nkind: HTTPProxy\nmetadata:\n  name: blog\n"),
+				Comment: "# LB_CONFLICT: Strategy could not be applied.\n# Please check.",
+			}},
+			want: `# LB_CONFLICT: Strategy could not be applied.
+# Please check.
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: blog
+`,
+		},
+		"items in a list, with anchors and merge keys": {
+			data: `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: first
+- apiVersion: contour.heptio.com/v1beta1
+  kind: IngressRoute
+  metadata: &meta
+    name: blog
+  spec:
+    routes:
+    - match: &prefix /
+      services: &services
+      - name: blog
+        port: 80
+- apiVersion: v1
+  kind: Service
+  metadata:
+    <<: *meta
+  prefix: *prefix
+  services: *services
+`,
+			replacements: []Replacement{{
+				Item: 1,
+				YAML: []byte(`apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: blog
+spec:
+  routes:
+  - conditions:
+    - prefix: /
+    services:
+    - name: blog
+      port: 80
+`),
+			}},
+			want: `apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: first
+  - apiVersion: projectcontour.io/v1
+    kind: HTTPProxy
+    metadata: &meta
+      name: blog
+    spec:
+      routes:
+        - conditions:
+            - prefix: /
+          services: &services
+            - name: blog
+              port: 80
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      <<: *meta
+    prefix: /
+    services: *services
+`,
+		},
+		"the indent of the document is kept": {
+			data: `apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+    name: blog
+    namespace: default
+`,
+			replacements: []Replacement{{
+				Item: -1,
+				YAML: []byte("apiVersion: projectcontour.io/v1\nkind: HTTPProxy\nmetadata:\n  name: blog\n  namespace: default\n"),
+			}},
+			want: `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+    name: blog
+    namespace: default
+`,
+		},
+		"missing item": {
+			data: "apiVersion: v1\nkind: List\nitems: []\n",
+			replacements: []Replacement{{
+				Item: 0,
+				YAML: []byte("kind: HTTPProxy\n"),
+			}},
+			wantErr: true,
+		},
+		"empty document": {
+			data: "# just a comment\n",
+			replacements: []Replacement{{
+				Item: -1,
+				YAML: []byte("kind: HTTPProxy\n"),
+			}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := Replace([]byte(tc.data), tc.replacements)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got:\n%s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(got), tc.want); diff != "" {
+				t.Fatalf("Replace mismatch:\n%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Document is a single YAML document read from a stream.
+type Document struct {
+	// Index is the position of the document in the stream, starting at 0.
+	// Documents with no content aren't counted.
+	Index int
+	// Line is the line of the stream the document starts on, starting at 1.
+	// That's the line of the `---` before it, if there is one.
+	Line int
+	Data []byte
+	// Offset is the position of Data in the stream, in bytes.
+	Offset int
+}
+
+// DocumentReader reads YAML documents from a stream, one at a time, the
+// same way kubectl does: documents are split on lines that are only `---`.
+type DocumentReader struct {
+	documents *yaml.YAMLReader
+	// raw holds the bytes the YAMLReader has read from the stream, but
+	// that haven't been returned in a document yet.
+	raw bytes.Buffer
+	// line is the number of lines returned so far.
+	line int
+	// offset is the number of bytes returned so far.
+	offset int
+	index  int
+	// separated is true if the last line returned was the separator
+	// that ended a document.
+	separated bool
+}
+
+// NewDocumentReader returns a DocumentReader that reads from r.
+func NewDocumentReader(r io.Reader) *DocumentReader {
+	d := &DocumentReader{}
+	d.documents = yaml.NewYAMLReader(bufio.NewReader(io.TeeReader(r, &d.raw)))
+	return d
+}
+
+// Read returns the next document with some content in it, or io.EOF
+// once there are no more.
+func (d *DocumentReader) Read() (*Document, error) {
+
+	for {
+		data, err := d.documents.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		// The YAMLReader ends every line with a newline, so the document's
+		// lines are taken from the stream as they were. It drops the
+		// separator that ends a document, but keeps the ones before it.
+		lines := bytes.Count(data, []byte("\n"))
+		doc := &Document{Line: d.line + 1}
+		if d.separated {
+			doc.Line = d.line
+		}
+		for ; lines > 0 && isSeparator(d.peekLine()); lines-- {
+			d.readLine()
+			doc.Line = d.line
+		}
+		doc.Offset = d.offset
+		for ; lines > 0; lines-- {
+			doc.Data = append(doc.Data, d.readLine()...)
+		}
+		d.separated = isSeparator(d.peekLine())
+		if d.separated {
+			d.readLine()
+		}
+
+		if hasContent(doc.Data) {
+			doc.Index = d.index
+			d.index++
+			return doc, nil
+		}
+	}
+}
+
+// peekLine returns the next line of the stream, without its newline.
+func (d *DocumentReader) peekLine() []byte {
+	line := d.raw.Bytes()
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	return line
+}
+
+// readLine returns the next line of the stream, with its newline.
+func (d *DocumentReader) readLine() []byte {
+	line, _ := d.raw.ReadBytes('\n')
+	d.line++
+	d.offset += len(line)
+	return line
+}
+
+// isSeparator returns true if line is a document separator for the
+// YAMLReader, which is `---` followed by nothing but whitespace.
+func isSeparator(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("---")) && strings.TrimRightFunc(string(line[3:]), unicode.IsSpace) == ""
+}
+
+// hasContent returns true if data has anything other than whitespace,
+// comments and directives in it.
+func hasContent(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("%")) {
+			continue
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			return true
+		}
+	}
+	return false
+}
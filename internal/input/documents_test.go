@@ -0,0 +1,146 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDocumentReader(t *testing.T) {
+
+	type document struct {
+		Line int
+		Data string
+	}
+
+	tests := map[string]struct {
+		input string
+		want  []document
+	}{
+		"single document, no marker": {
+			input: "kind: IngressRoute\n",
+			want: []document{
+				{Line: 1, Data: "kind: IngressRoute\n"},
+			},
+		},
+		"two documents": {
+			input: "---\nkind: IngressRoute\n---\nkind: Service\n",
+			want: []document{
+				{Line: 1, Data: "kind: IngressRoute\n"},
+				{Line: 3, Data: "kind: Service\n"},
+			},
+		},
+		"no trailing newline": {
+			input: "---\nkind: IngressRoute\n---\nkind: Service",
+			want: []document{
+				{Line: 1, Data: "kind: IngressRoute\n"},
+				{Line: 3, Data: "kind: Service"},
+			},
+		},
+		"dashes inside values": {
+			input: "---\nmetadata:\n  annotations:\n    note: a---b\n    rule: ---\n# ---- comment\n",
+			want: []document{
+				{Line: 1, Data: "metadata:\n  annotations:\n    note: a---b\n    rule: ---\n# ---- comment\n"},
+			},
+		},
+		"dashes inside block scalars": {
+			input: "---\ndata: |\n  header\n  ---\n  footer\nmore: >-\n  ---\n\n  --- x\n---\nkind: Service\n",
+			want: []document{
+				{Line: 1, Data: "data: |\n  header\n  ---\n  footer\nmore: >-\n  ---\n\n  --- x\n"},
+				{Line: 10, Data: "kind: Service\n"},
+			},
+		},
+		"longer dashes are not a separator": {
+			input: "kind: IngressRoute\n----\n",
+			want: []document{
+				{Line: 1, Data: "kind: IngressRoute\n----\n"},
+			},
+		},
+		"trailing whitespace after the separator": {
+			input: "kind: IngressRoute\n---  \t\nkind: Service\n",
+			want: []document{
+				{Line: 1, Data: "kind: IngressRoute\n"},
+				{Line: 2, Data: "kind: Service\n"},
+			},
+		},
+		"content after the marker isn't a separator": {
+			input: "--- # first\nkind: IngressRoute\n",
+			want: []document{
+				{Line: 1, Data: "--- # first\nkind: IngressRoute\n"},
+			},
+		},
+		"document end markers and directives stay in the document": {
+			input: "%YAML 1.2\n---\nkind: IngressRoute\n...\n%YAML 1.2\n---\nkind: Service\n...\n",
+			want: []document{
+				{Line: 2, Data: "kind: IngressRoute\n...\n%YAML 1.2\n"},
+				{Line: 6, Data: "kind: Service\n...\n"},
+			},
+		},
+		"empty and comment only documents are skipped": {
+			input: "---\n---\n# just a comment\n---\nkind: IngressRoute\n---\n\n",
+			want: []document{
+				{Line: 4, Data: "kind: IngressRoute\n"},
+			},
+		},
+		"CRLF line endings": {
+			input: "---\r\nkind: IngressRoute\r\n---\r\nkind: Service\r\n",
+			want: []document{
+				{Line: 1, Data: "kind: IngressRoute\r\n"},
+				{Line: 3, Data: "kind: Service\r\n"},
+			},
+		},
+		"a document larger than the read buffer": {
+			input: "kind: IngressRoute\nnote: " + strings.Repeat("x", 10000) + "\n---\nkind: Service\n",
+			want: []document{
+				{Line: 1, Data: "kind: IngressRoute\nnote: " + strings.Repeat("x", 10000) + "\n"},
+				{Line: 3, Data: "kind: Service\n"},
+			},
+		},
+		"empty input": {
+			input: "",
+			want:  nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			reader := NewDocumentReader(strings.NewReader(tc.input))
+			var got []document
+			for {
+				doc, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if doc.Index != len(got) {
+					t.Fatalf("expected document index %d, got %d", len(got), doc.Index)
+				}
+				// Every document's data can be found at its offset.
+				if end := doc.Offset + len(doc.Data); end > len(tc.input) || tc.input[doc.Offset:end] != string(doc.Data) {
+					t.Fatalf("document %d isn't at offset %d", doc.Index, doc.Offset)
+				}
+				got = append(got, document{Line: doc.Line, Data: string(doc.Data)})
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package input finds and reads the YAML documents to be translated
+package input
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Stdin is the file name that means standard input.
+const Stdin = "-"
+
+// stdin is where Stdin is read from.
+var stdin io.Reader = os.Stdin
+
+// manifestExtensions are the file extensions read from directories.
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// Files expands command line arguments into a list of files.
+// Each argument can be a file, a directory or a glob pattern.
+// Directories are searched for files with a .yaml, .yml or .json extension,
+// including their subdirectories if recursive is true.
+// The files for each argument are sorted, so the result is the same for
+// every run, and a file is only returned once.
+// An argument of "-", or no arguments at all, means standard input.
+func Files(args []string, recursive bool) ([]string, error) {
+
+	if len(args) == 0 {
+		return []string{Stdin}, nil
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == Stdin {
+			add(Stdin)
+			continue
+		}
+
+		paths := []string{arg}
+		if hasMeta(arg) {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s, %s", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+			paths = matches
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(path)
+				continue
+			}
+			dirFiles, err := dirFiles(path, recursive)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range dirFiles {
+				add(file)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// Open opens a file returned by Files, which may be standard input.
+func Open(file string) (io.ReadCloser, error) {
+	if file == Stdin {
+		return ioutil.NopCloser(stdin), nil
+	}
+	return os.Open(file)
+}
+
+// dirFiles returns the manifest files in dir, sorted by path.
+func dirFiles(dir string, recursive bool) ([]string, error) {
+
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if manifestExtensions[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// hasMeta returns true if path contains any of the characters
+// recognized by filepath.Match.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
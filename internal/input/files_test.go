@@ -0,0 +1,157 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package input
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFiles(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "ir2proxy-input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, file := range []string{
+		"b.yaml",
+		"a.yml",
+		"c.json",
+		"notes.txt",
+		"sub/d.yaml",
+		"sub/deeper/e.yaml",
+	} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("---\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		args      []string
+		recursive bool
+		want      []string
+		wantErr   bool
+	}{
+		"single file": {
+			args: []string{"b.yaml"},
+			want: []string{"b.yaml"},
+		},
+		"files keep argument order": {
+			args: []string{"b.yaml", "a.yml"},
+			want: []string{"b.yaml", "a.yml"},
+		},
+		"file given twice": {
+			args: []string{"b.yaml", "b.yaml"},
+			want: []string{"b.yaml"},
+		},
+		"directory": {
+			args: []string{"."},
+			want: []string{"a.yml", "b.yaml", "c.json"},
+		},
+		"directory, recursive": {
+			args:      []string{"."},
+			recursive: true,
+			want:      []string{"a.yml", "b.yaml", "c.json", "sub/d.yaml", "sub/deeper/e.yaml"},
+		},
+		"glob": {
+			args: []string{"*.y*ml"},
+			want: []string{"a.yml", "b.yaml"},
+		},
+		"glob matching a directory": {
+			args: []string{"s*"},
+			want: []string{"sub/d.yaml"},
+		},
+		"glob with no matches": {
+			args:    []string{"*.nothing"},
+			wantErr: true,
+		},
+		"stdin": {
+			args: []string{"-"},
+			want: []string{"-"},
+		},
+		"stdin and a file": {
+			args: []string{"b.yaml", "-"},
+			want: []string{"b.yaml", "-"},
+		},
+		"missing file": {
+			args:    []string{"missing.yaml"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var args []string
+			for _, arg := range tc.args {
+				if arg != Stdin {
+					arg = filepath.Join(dir, arg)
+				}
+				args = append(args, arg)
+			}
+			got, err := Files(args, tc.recursive)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want error: %v, got: %v", tc.wantErr, err)
+			}
+			var want []string
+			for _, file := range tc.want {
+				if file != Stdin {
+					file = filepath.Join(dir, file)
+				}
+				want = append(want, file)
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestFilesNoArgs(t *testing.T) {
+	got, err := Files(nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(got, []string{Stdin}); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestOpenStdin(t *testing.T) {
+	defer func(r io.Reader) { stdin = r }(stdin)
+	stdin = strings.NewReader("---\nkind: IngressRoute\n")
+
+	reader, err := Open(Stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "---\nkind: IngressRoute\n" {
+		t.Fatalf("unexpected stdin contents %q", got)
+	}
+}
@@ -11,17 +11,44 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package k8sdecoder decodes YAML []bytes into IngressRoute objects
+// Package k8sdecoder decodes YAML []bytes into IngressRoute and Ingress objects
 package k8sdecoder
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/ghodss/yaml"
 	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
 	contourscheme "github.com/projectcontour/contour/apis/generated/clientset/versioned/scheme"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
+// Item is an object decoded from a document.
+type Item struct {
+	// Index is the position of the object in the List or array it came from,
+	// or -1 if the document held a single object.
+	Index int
+	// Object is the decoded object. Kinds that aren't known to the decoder
+	// are decoded as *unstructured.Unstructured, and extensions/v1beta1
+	// Ingresses are converted to *netv1beta1.Ingress.
+	Object runtime.Object
+	// GroupVersionKind is the type of the object in the input.
+	GroupVersionKind schema.GroupVersionKind
+	// Raw is the JSON the object was decoded from.
+	Raw []byte
+}
+
 // DecodeIngressRoute decodes a given byte stream into a IngressRoute or returns an error.
 func DecodeIngressRoute(input []byte) (*irv1beta1.IngressRoute, error) {
 	contourscheme.AddToScheme(scheme.Scheme)
@@ -38,3 +65,136 @@ func DecodeIngressRoute(input []byte) (*irv1beta1.IngressRoute, error) {
 	}
 
 }
+
+// Decode decodes a given byte stream into the objects it holds.
+// A v1 List, an IngressRouteList, a TLSCertificateDelegationList, an IngressList, or a JSON or YAML array
+// is expanded into an Item for each object in it.
+func Decode(input []byte) ([]Item, error) {
+
+	jsondata, err := yaml.YAMLToJSON(input)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse yaml, %s", err)
+	}
+
+	if trimmed := bytes.TrimSpace(jsondata); len(trimmed) > 0 && trimmed[0] == '[' {
+		var rawItems []json.RawMessage
+		if err := json.Unmarshal(trimmed, &rawItems); err != nil {
+			return nil, fmt.Errorf("could not parse array, %s", err)
+		}
+		items := make([]Item, 0, len(rawItems))
+		for index, rawItem := range rawItems {
+			item, err := decodeObject(rawItem)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %s", index, err)
+			}
+			item.Index = index
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	item, err := decodeObject(jsondata)
+	if err != nil {
+		return nil, err
+	}
+
+	switch list := item.Object.(type) {
+	case *corev1.List:
+		items := make([]Item, 0, len(list.Items))
+		for index, rawItem := range list.Items {
+			item, err := decodeObject(rawItem.Raw)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %s", index, err)
+			}
+			item.Index = index
+			items = append(items, item)
+		}
+		return items, nil
+	case *irv1beta1.IngressRouteList, *irv1beta1.TLSCertificateDelegationList, *hpv1.HTTPProxyList, *extv1beta1.IngressList, *netv1beta1.IngressList:
+		return typedListItems(list, item.GroupVersionKind)
+	}
+
+	return []Item{item}, nil
+}
+
+// typedListItems returns an Item for each object in a typed List, like an
+// IngressRouteList, whose type is listKind.
+func typedListItems(list runtime.Object, listKind schema.GroupVersionKind) ([]Item, error) {
+	objects, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	// The items of a typed List don't need to have their type set.
+	groupVersionKind := listKind.GroupVersion().WithKind(strings.TrimSuffix(listKind.Kind, "List"))
+	items := make([]Item, 0, len(objects))
+	for index, obj := range objects {
+		obj = obj.DeepCopyObject()
+		obj.GetObjectKind().SetGroupVersionKind(groupVersionKind)
+		if ing, ok := obj.(*extv1beta1.Ingress); ok {
+			if obj, err = ingressToNetworking(ing); err != nil {
+				return nil, fmt.Errorf("item %d: %s", index, err)
+			}
+		}
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %s", index, err)
+		}
+		items = append(items, Item{
+			Index:            index,
+			Object:           obj,
+			GroupVersionKind: groupVersionKind,
+			Raw:              raw,
+		})
+	}
+	return items, nil
+}
+
+// ingressToNetworking converts an extensions/v1beta1 Ingress to a
+// networking.k8s.io/v1beta1 one, so that only one of them needs translating.
+func ingressToNetworking(ing *extv1beta1.Ingress) (*netv1beta1.Ingress, error) {
+	// The types have the same fields, so they can be converted through JSON.
+	data, err := json.Marshal(ing)
+	if err != nil {
+		return nil, err
+	}
+	converted := &netv1beta1.Ingress{}
+	if err := json.Unmarshal(data, converted); err != nil {
+		return nil, err
+	}
+	converted.SetGroupVersionKind(netv1beta1.SchemeGroupVersion.WithKind("Ingress"))
+	return converted, nil
+}
+
+func decodeObject(input []byte) (Item, error) {
+	contourscheme.AddToScheme(scheme.Scheme)
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	obj, groupVersionKind, err := decode(input, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		// Not a kind the scheme knows about, like a CRD from another project.
+		unknown := &unstructured.Unstructured{}
+		if err := unknown.UnmarshalJSON(input); err != nil {
+			return Item{}, fmt.Errorf("could not parse yaml, %s", err)
+		}
+		return Item{
+			Index:            -1,
+			Object:           unknown,
+			GroupVersionKind: unknown.GroupVersionKind(),
+			Raw:              input,
+		}, nil
+	}
+	if err != nil {
+		return Item{}, fmt.Errorf("could not parse yaml, %s", err)
+	}
+	if ing, ok := obj.(*extv1beta1.Ingress); ok {
+		obj, err = ingressToNetworking(ing)
+		if err != nil {
+			return Item{}, fmt.Errorf("could not convert Ingress, %s", err)
+		}
+	}
+	return Item{
+		Index:            -1,
+		Object:           obj,
+		GroupVersionKind: *groupVersionKind,
+		Raw:              input,
+	}, nil
+}
@@ -14,8 +14,15 @@
 package k8sdecoder
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 )
 
 func TestDecodeIngressRoute(t *testing.T) {
@@ -72,3 +79,382 @@ spec:
 		})
 	}
 }
+
+func TestDecodeTLSCertificateDelegation(t *testing.T) {
+
+	tests := map[string]struct {
+		input []byte
+		want  bool
+	}{
+		"Minimal valid TLSCertificateDelegation": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: TLSCertificateDelegation
+metadata:
+  name: basic
+spec:
+  delegations: []
+`),
+			want: true,
+		},
+		"Not a TLSCertificateDelegation": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: basic
+spec: {}
+`),
+			want: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			items, err := Decode(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := items[0].Object.(*irv1beta1.TLSCertificateDelegation); ok != tc.want {
+				t.Fatalf("want TLSCertificateDelegation: %t, got: %T", tc.want, items[0].Object)
+			}
+		})
+	}
+}
+
+func TestDecodeIngress(t *testing.T) {
+
+	tests := map[string]struct {
+		input      []byte
+		want       string
+		notIngress bool
+	}{
+		"extensions/v1beta1 Ingress": {
+			input: []byte(`
+---
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: basic
+spec:
+  backend:
+    serviceName: s1
+    servicePort: 80
+`),
+			want: "s1",
+		},
+		"networking.k8s.io/v1beta1 Ingress": {
+			input: []byte(`
+---
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: basic
+spec:
+  backend:
+    serviceName: s1
+    servicePort: 80
+`),
+			want: "s1",
+		},
+		"Not an Ingress": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: basic
+spec: {}
+`),
+			notIngress: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			items, err := Decode(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ing, ok := items[0].Object.(*netv1beta1.Ingress)
+			if ok == tc.notIngress {
+				t.Fatalf("want Ingress: %t, got: %T", !tc.notIngress, items[0].Object)
+			}
+			if !ok {
+				return
+			}
+			if ing.Spec.Backend == nil || ing.Spec.Backend.ServiceName != tc.want {
+				t.Fatalf("want backend %s, got: %+v", tc.want, ing.Spec.Backend)
+			}
+			if ing.APIVersion != "networking.k8s.io/v1beta1" {
+				t.Fatalf("want networking.k8s.io/v1beta1, got: %s", ing.APIVersion)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+
+	type result struct {
+		Index int
+		Kind  string
+		Name  string
+	}
+
+	tests := map[string]struct {
+		input   []byte
+		want    []result
+		wantErr bool
+	}{
+		"single IngressRoute": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: basic
+spec: {}
+`),
+			want: []result{{Index: -1, Kind: "IngressRoute", Name: "basic"}},
+		},
+		"v1 List": {
+			input: []byte(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: contour.heptio.com/v1beta1
+  kind: IngressRoute
+  metadata:
+    name: first
+  spec: {}
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: s1
+- apiVersion: contour.heptio.com/v1beta1
+  kind: IngressRoute
+  metadata:
+    name: second
+  spec: {}
+`),
+			want: []result{
+				{Index: 0, Kind: "IngressRoute", Name: "first"},
+				{Index: 1, Kind: "Service", Name: "s1"},
+				{Index: 2, Kind: "IngressRoute", Name: "second"},
+			},
+		},
+		"IngressRouteList": {
+			input: []byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRouteList
+items:
+- metadata:
+    name: first
+  spec: {}
+- metadata:
+    name: second
+  spec: {}
+`),
+			want: []result{
+				{Index: 0, Kind: "IngressRoute", Name: "first"},
+				{Index: 1, Kind: "IngressRoute", Name: "second"},
+			},
+		},
+		"TLSCertificateDelegationList": {
+			input: []byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: TLSCertificateDelegationList
+items:
+- metadata:
+    name: first
+  spec:
+    delegations: []
+`),
+			want: []result{
+				{Index: 0, Kind: "TLSCertificateDelegation", Name: "first"},
+			},
+		},
+		"HTTPProxyList": {
+			input: []byte(`
+apiVersion: projectcontour.io/v1
+kind: HTTPProxyList
+items:
+- metadata:
+    name: first
+  spec: {}
+`),
+			want: []result{
+				{Index: 0, Kind: "HTTPProxy", Name: "first"},
+			},
+		},
+		"extensions/v1beta1 IngressList": {
+			input: []byte(`
+apiVersion: extensions/v1beta1
+kind: IngressList
+items:
+- metadata:
+    name: first
+  spec: {}
+`),
+			want: []result{
+				{Index: 0, Kind: "Ingress", Name: "first"},
+			},
+		},
+		"networking.k8s.io/v1beta1 IngressList": {
+			input: []byte(`
+apiVersion: networking.k8s.io/v1beta1
+kind: IngressList
+items:
+- metadata:
+    name: first
+  spec: {}
+- metadata:
+    name: second
+  spec: {}
+`),
+			want: []result{
+				{Index: 0, Kind: "Ingress", Name: "first"},
+				{Index: 1, Kind: "Ingress", Name: "second"},
+			},
+		},
+		"JSON array": {
+			input: []byte(`[
+  {"apiVersion": "contour.heptio.com/v1beta1", "kind": "IngressRoute", "metadata": {"name": "first"}, "spec": {}},
+  {"apiVersion": "contour.heptio.com/v1beta1", "kind": "IngressRoute", "metadata": {"name": "second"}, "spec": {}}
+]`),
+			want: []result{
+				{Index: 0, Kind: "IngressRoute", Name: "first"},
+				{Index: 1, Kind: "IngressRoute", Name: "second"},
+			},
+		},
+		"YAML array": {
+			input: []byte(`
+- apiVersion: contour.heptio.com/v1beta1
+  kind: IngressRoute
+  metadata:
+    name: first
+  spec: {}
+`),
+			want: []result{
+				{Index: 0, Kind: "IngressRoute", Name: "first"},
+			},
+		},
+		"unknown kind": {
+			input: []byte(`
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w1
+`),
+			want: []result{{Index: -1, Kind: "Widget", Name: "w1"}},
+		},
+		"invalid item in List": {
+			input: []byte(`
+apiVersion: v1
+kind: List
+items:
+- metadata:
+    name: notype
+`),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			items, err := Decode(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("want error: %v, got: %v", tc.wantErr, err)
+			}
+			var got []result
+			for _, item := range items {
+				accessor, err := meta.Accessor(item.Object)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, result{
+					Index: item.Index,
+					Kind:  item.GroupVersionKind.Kind,
+					Name:  accessor.GetName(),
+				})
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+// TestDecodeTypedListItems checks the types of the items of typed Lists,
+// which don't have their own apiVersion and kind in the input.
+func TestDecodeTypedListItems(t *testing.T) {
+
+	type result struct {
+		GroupVersionKind string
+		Object           string
+		Raw              string
+	}
+
+	tests := map[string]struct {
+		input []byte
+		want  result
+	}{
+		"IngressRouteList": {
+			input: []byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRouteList
+items:
+- metadata:
+    name: first
+`),
+			want: result{
+				GroupVersionKind: "contour.heptio.com/v1beta1, Kind=IngressRoute",
+				Object:           "github.com/projectcontour/contour/apis/contour/v1beta1.IngressRoute",
+				Raw:              "contour.heptio.com/v1beta1",
+			},
+		},
+		"extensions/v1beta1 IngressList": {
+			input: []byte(`
+apiVersion: extensions/v1beta1
+kind: IngressList
+items:
+- metadata:
+    name: first
+`),
+			// The Ingress is converted, but its type in the input is kept.
+			want: result{
+				GroupVersionKind: "extensions/v1beta1, Kind=Ingress",
+				Object:           "k8s.io/api/networking/v1beta1.Ingress",
+				Raw:              "networking.k8s.io/v1beta1",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			items, err := Decode(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 {
+				t.Fatalf("expected 1 item, got %d", len(items))
+			}
+			var raw struct {
+				APIVersion string `json:"apiVersion"`
+			}
+			if err := json.Unmarshal(items[0].Raw, &raw); err != nil {
+				t.Fatal(err)
+			}
+			got := result{
+				GroupVersionKind: items[0].GroupVersionKind.String(),
+				Object:           reflect.TypeOf(items[0].Object).Elem().PkgPath() + "." + reflect.TypeOf(items[0].Object).Elem().Name(),
+				Raw:              raw.APIVersion,
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
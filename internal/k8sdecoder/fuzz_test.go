@@ -0,0 +1,47 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package k8sdecoder
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzDecodeIngressRoute decodes arbitrary input. The seed corpus is the
+// input.yaml of each translator test case.
+func FuzzDecodeIngressRoute(f *testing.F) {
+
+	inputs, err := filepath.Glob("../translator/testdata/*/input.yaml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, input := range inputs {
+		data, err := ioutil.ReadFile(input)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		ir, err := DecodeIngressRoute(input)
+		if err == nil && ir == nil {
+			t.Fatal("no IngressRoute and no error")
+		}
+	})
+}
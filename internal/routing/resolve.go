@@ -0,0 +1,254 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Request is an HTTP request to resolve against a set of routes.
+type Request struct {
+	Method string
+	// Secure is true for an HTTPS request.
+	Secure bool
+	Host   string
+	Path   string
+}
+
+// ParseRequest parses a request like "GET https://foo.bar.com/api/v2/x".
+// The method is optional, and defaults to GET.
+func ParseRequest(request string) (Request, error) {
+
+	fields := strings.Fields(request)
+	method := "GET"
+	switch len(fields) {
+	case 1:
+	case 2:
+		method = strings.ToUpper(fields[0])
+	default:
+		return Request{}, fmt.Errorf("invalid request %q, must be a URL, optionally after a method", request)
+	}
+
+	u, err := url.Parse(fields[len(fields)-1])
+	if err != nil {
+		return Request{}, fmt.Errorf("invalid request %q: %s", request, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Request{}, fmt.Errorf("invalid request %q, the URL must start with http:// or https://", request)
+	}
+	if u.Hostname() == "" {
+		return Request{}, fmt.Errorf("invalid request %q, the URL must have a host", request)
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return Request{
+		Method: method,
+		Secure: u.Scheme == "https",
+		Host:   u.Hostname(),
+		Path:   path,
+	}, nil
+}
+
+func (r Request) String() string {
+	scheme := "http"
+	if r.Secure {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s %s://%s%s", r.Method, scheme, r.Host, r.Path)
+}
+
+// Resolution is where a request is sent.
+type Resolution struct {
+	// Route is the route the request matched, or nil if it matched none.
+	Route *Route
+	// Redirect is true if the request is redirected to HTTPS.
+	Redirect bool
+	// Path is the path sent upstream, after any prefix rewrite.
+	Path string
+	// Upstreams are the services the request is sent to.
+	Upstreams []Upstream
+}
+
+// Upstream is a service that a share of the requests for a Route are sent to.
+type Upstream struct {
+	Namespace string
+	Name      string
+	Port      int
+	// Percent is the percentage of requests that are sent to the service.
+	Percent float64
+}
+
+func (u Upstream) String() string {
+	return fmt.Sprintf("%s/%s:%d (%s%%)", u.Namespace, u.Name, u.Port, formatPercent(u.Percent))
+}
+
+// Resolve returns where a request is sent by routes, the way Envoy matches the
+// routes Contour gives it. The longest prefix that the path starts with wins,
+// and requests are split between the route's services by weight.
+func Resolve(routes []Route, request Request) Resolution {
+
+	var match *Route
+	for index := range routes {
+		route := &routes[index]
+		if route.Host != request.Host {
+			continue
+		}
+		// A TCP proxy takes all the HTTPS requests for its virtual host.
+		if route.TCPProxy {
+			if request.Secure {
+				return Resolution{Route: route, Path: request.Path, Upstreams: upstreams(route.Services)}
+			}
+			continue
+		}
+		if request.Secure && !route.Secure {
+			continue
+		}
+		if !strings.HasPrefix(request.Path, route.Prefix) {
+			continue
+		}
+		// Contour replaces a route with a later one for the same prefix.
+		if match == nil || len(route.Prefix) >= len(match.Prefix) {
+			match = route
+		}
+	}
+
+	if match == nil {
+		return Resolution{}
+	}
+	if !request.Secure && match.HTTPSRedirect {
+		return Resolution{Route: match, Redirect: true}
+	}
+
+	path := request.Path
+	if match.PrefixRewrite != "" {
+		path = match.PrefixRewrite + strings.TrimPrefix(path, match.Prefix)
+	}
+	return Resolution{Route: match, Path: path, Upstreams: upstreams(match.Services)}
+}
+
+// upstreams splits requests between services by weight. Like Contour, services
+// are weighted equally if none of them have a weight.
+func upstreams(services []Service) []Upstream {
+
+	var total uint32
+	for _, service := range services {
+		total += service.Weight
+	}
+
+	var upstreams []Upstream
+	for _, service := range services {
+		share := 1 / float64(len(services))
+		if total > 0 {
+			share = float64(service.Weight) / float64(total)
+		}
+		upstreams = append(upstreams, Upstream{
+			Namespace: service.Namespace,
+			Name:      service.Name,
+			Port:      service.Port,
+			Percent:   share * 100,
+		})
+	}
+	return upstreams
+}
+
+// SameDestination returns true if two resolutions send a request to the same
+// services, with the same path, in the same proportions.
+func (r Resolution) SameDestination(other Resolution) bool {
+	if (r.Route == nil) != (other.Route == nil) || r.Redirect != other.Redirect || r.Path != other.Path {
+		return false
+	}
+	if len(r.Upstreams) != len(other.Upstreams) {
+		return false
+	}
+	for index := range r.Upstreams {
+		if r.Upstreams[index] != other.Upstreams[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Resolution) String() string {
+	switch {
+	case r.Route == nil:
+		return "no route"
+	case r.Redirect:
+		return fmt.Sprintf("route %s: redirect to HTTPS", r.Route.Prefix)
+	}
+
+	upstreams := make([]string, len(r.Upstreams))
+	for index, upstream := range r.Upstreams {
+		upstreams[index] = upstream.String()
+	}
+	if r.Route.TCPProxy {
+		return fmt.Sprintf("tcpproxy: %s", strings.Join(upstreams, ", "))
+	}
+	return fmt.Sprintf("route %s, upstream path %s: %s", r.Route.Prefix, r.Path, strings.Join(upstreams, ", "))
+}
+
+// ProbeRequests returns requests that exercise the routes in each set: a request
+// for each prefix, and for the prefix followed by a '/', over HTTP and HTTPS.
+// The requests are sorted, and each one is only returned once.
+func ProbeRequests(routeSets ...[]Route) []Request {
+
+	seen := make(map[Request]bool)
+	var requests []Request
+	add := func(request Request) {
+		if !seen[request] {
+			seen[request] = true
+			requests = append(requests, request)
+		}
+	}
+
+	for _, routes := range routeSets {
+		for _, route := range routes {
+			if route.TCPProxy {
+				add(Request{Method: "GET", Secure: true, Host: route.Host, Path: "/"})
+				continue
+			}
+			paths := []string{route.Prefix}
+			if !strings.HasSuffix(route.Prefix, "/") {
+				paths = append(paths, route.Prefix+"/")
+			}
+			for _, path := range paths {
+				add(Request{Method: "GET", Host: route.Host, Path: path})
+				add(Request{Method: "GET", Secure: true, Host: route.Host, Path: path})
+			}
+		}
+	}
+
+	sort.SliceStable(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return !a.Secure && b.Secure
+	})
+	return requests
+}
+
+// formatPercent formats a percentage with at most two decimal places.
+func formatPercent(percent float64) string {
+	formatted := strings.TrimRight(fmt.Sprintf("%.2f", percent), "0")
+	return strings.TrimSuffix(formatted, ".")
+}
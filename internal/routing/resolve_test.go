@@ -0,0 +1,199 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRequest(t *testing.T) {
+
+	tests := map[string]struct {
+		input   string
+		want    Request
+		wantErr string
+	}{
+		"method and URL": {
+			input: "GET https://foo.bar.com/api/v2/x",
+			want:  Request{Method: "GET", Secure: true, Host: "foo.bar.com", Path: "/api/v2/x"},
+		},
+		"URL only": {
+			input: "http://foo.bar.com",
+			want:  Request{Method: "GET", Host: "foo.bar.com", Path: "/"},
+		},
+		"lower case method and port": {
+			input: "post http://foo.bar.com:8080/api",
+			want:  Request{Method: "POST", Host: "foo.bar.com", Path: "/api"},
+		},
+		"no scheme": {
+			input:   "GET foo.bar.com/api",
+			wantErr: `invalid request "GET foo.bar.com/api", the URL must start with http:// or https://`,
+		},
+		"too many fields": {
+			input:   "GET https://foo.bar.com/ HTTP/1.1",
+			wantErr: `invalid request "GET https://foo.bar.com/ HTTP/1.1", must be a URL, optionally after a method`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRequest(tc.input)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatalf("Request mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+
+	routes := []Route{
+		{Host: "foo.bar.com", Prefix: "/", Secure: true, HTTPSRedirect: true, Services: []Service{{Namespace: "default", Name: "root", Port: 80}}},
+		{Host: "foo.bar.com", Prefix: "/api", Secure: true, Services: []Service{
+			{Namespace: "default", Name: "api-v1", Port: 80, Weight: 1},
+			{Namespace: "default", Name: "api-v2", Port: 80, Weight: 3},
+		}},
+		{Host: "foo.bar.com", Prefix: "/api/v2", Secure: true, PrefixRewrite: "/v2", Services: []Service{
+			{Namespace: "default", Name: "a", Port: 80},
+			{Namespace: "default", Name: "b", Port: 80},
+			{Namespace: "default", Name: "c", Port: 80},
+		}},
+		{Host: "foo.bar.com", Prefix: "/dup", Services: []Service{{Namespace: "default", Name: "first", Port: 80}}},
+		{Host: "foo.bar.com", Prefix: "/dup", Services: []Service{{Namespace: "default", Name: "second", Port: 80}}},
+		{Host: "tcp.bar.com", TCPProxy: true, Secure: true, Services: []Service{{Namespace: "default", Name: "tls", Port: 443}}},
+		{Host: "tcp.bar.com", Prefix: "/", Services: []Service{{Namespace: "default", Name: "plain", Port: 80}}},
+	}
+
+	tests := map[string]struct {
+		request string
+		want    string
+	}{
+		"longest prefix": {
+			request: "GET https://foo.bar.com/api/v2/x",
+			want:    "route /api/v2, upstream path /v2/x: default/a:80 (33.33%), default/b:80 (33.33%), default/c:80 (33.33%)",
+		},
+		"prefixes aren't path segments": {
+			request: "GET https://foo.bar.com/apiary",
+			want:    "route /api, upstream path /apiary: default/api-v1:80 (25%), default/api-v2:80 (75%)",
+		},
+		"redirect": {
+			request: "GET http://foo.bar.com/",
+			want:    "route /: redirect to HTTPS",
+		},
+		"permitted insecure": {
+			request: "GET http://foo.bar.com/api",
+			want:    "route /api, upstream path /api: default/api-v1:80 (25%), default/api-v2:80 (75%)",
+		},
+		"not served over HTTPS": {
+			request: "GET https://foo.bar.com/dup",
+			want:    "route /, upstream path /dup: default/root:80 (100%)",
+		},
+		"later route for the same prefix wins": {
+			request: "GET http://foo.bar.com/dup",
+			want:    "route /dup, upstream path /dup: default/second:80 (100%)",
+		},
+		"tcpproxy": {
+			request: "GET https://tcp.bar.com/",
+			want:    "tcpproxy: default/tls:443 (100%)",
+		},
+		"tcpproxy over HTTP": {
+			request: "GET http://tcp.bar.com/",
+			want:    "route /, upstream path /: default/plain:80 (100%)",
+		},
+		"unknown host": {
+			request: "GET http://other.bar.com/",
+			want:    "no route",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			request, err := ParseRequest(tc.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(Resolve(routes, request).String(), tc.want); diff != "" {
+				t.Fatalf("Resolution mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestSameDestination(t *testing.T) {
+
+	a := []Route{{Host: "foo.bar.com", Prefix: "/", Services: []Service{
+		{Namespace: "default", Name: "s1", Port: 80, Weight: 90},
+		{Namespace: "default", Name: "s2", Port: 80, Weight: 10},
+	}}}
+	b := []Route{{Host: "foo.bar.com", Prefix: "/", Services: []Service{
+		{Namespace: "default", Name: "s1", Port: 80, Weight: 9},
+		{Namespace: "default", Name: "s2", Port: 80, Weight: 1},
+	}}}
+	c := []Route{{Host: "foo.bar.com", Prefix: "/", Services: []Service{
+		{Namespace: "default", Name: "s1", Port: 80},
+		{Namespace: "default", Name: "s2", Port: 80},
+	}}}
+
+	request := Request{Method: "GET", Host: "foo.bar.com", Path: "/"}
+	if !Resolve(a, request).SameDestination(Resolve(b, request)) {
+		t.Error("weights in the same proportions should have the same destination")
+	}
+	if Resolve(a, request).SameDestination(Resolve(c, request)) {
+		t.Error("different weights should have different destinations")
+	}
+	if Resolve(a, request).SameDestination(Resolve(nil, request)) {
+		t.Error("a route and no route should have different destinations")
+	}
+}
+
+func TestProbeRequests(t *testing.T) {
+
+	irRoutes := []Route{
+		{Host: "foo.bar.com", Prefix: "/"},
+		{Host: "foo.bar.com", Prefix: "/api"},
+	}
+	hpRoutes := []Route{
+		{Host: "foo.bar.com", Prefix: "/api/"},
+		{Host: "tcp.bar.com", TCPProxy: true},
+	}
+
+	var got []string
+	for _, request := range ProbeRequests(irRoutes, hpRoutes) {
+		got = append(got, request.String())
+	}
+
+	want := []string{
+		"GET http://foo.bar.com/",
+		"GET https://foo.bar.com/",
+		"GET http://foo.bar.com/api",
+		"GET https://foo.bar.com/api",
+		"GET http://foo.bar.com/api/",
+		"GET https://foo.bar.com/api/",
+		"GET https://tcp.bar.com/",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Fatalf("Requests mismatch:\n%v", diff)
+	}
+}
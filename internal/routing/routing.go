@@ -0,0 +1,515 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package routing flattens IngressRoute and HTTPProxy delegation trees into the
+// routes Contour builds from them, so that translations can be checked for
+// semantic equivalence rather than byte equality.
+//
+// The routes follow Contour 1.1's DAG builder. It doesn't model header conditions,
+// or whether Services and Secrets exist.
+package routing
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+)
+
+// Route is where requests for a path prefix on a virtual host are sent.
+type Route struct {
+	Host string
+	// Prefix is the full path prefix of the route. It's empty for a TCP proxy.
+	Prefix string
+	// TCPProxy is true if the route is the TCP proxy of the virtual host.
+	TCPProxy bool
+	// Secure is true if the route is served over HTTPS.
+	Secure bool
+	// HTTPSRedirect is true if plain HTTP requests are redirected to HTTPS.
+	HTTPSRedirect bool
+	// Services are sorted by namespace, name and port.
+	Services        []Service
+	PrefixRewrite   string
+	ResponseTimeout time.Duration
+	RetryPolicy     *RetryPolicy
+}
+
+// Service is a weighted upstream of a Route.
+type Service struct {
+	Namespace string
+	Name      string
+	Port      int
+	Weight    uint32
+}
+
+// RetryPolicy is the retry policy of a Route.
+type RetryPolicy struct {
+	NumRetries    uint32
+	PerTryTimeout time.Duration
+}
+
+type key struct {
+	namespace string
+	name      string
+}
+
+// FromIngressRoutes returns the routes of the root IngressRoutes in irs, following
+// delegation to the other IngressRoutes in irs, sorted by host and prefix.
+// Like Contour, it stops processing an IngressRoute at a route that doesn't
+// match the prefix it's delegated at, and doesn't follow delegation cycles.
+func FromIngressRoutes(irs []*irv1beta1.IngressRoute) []Route {
+
+	nodes := make(map[key]*irv1beta1.IngressRoute)
+	for _, ir := range irs {
+		nodes[key{namespace: ir.Namespace, name: ir.Name}] = ir
+	}
+
+	var routes []Route
+	for _, ir := range irs {
+		if ir.Spec.VirtualHost == nil {
+			continue
+		}
+		host := ir.Spec.VirtualHost.Fqdn
+		var enforceTLS, passthrough bool
+		if tls := ir.Spec.VirtualHost.TLS; tls != nil {
+			enforceTLS = tls.SecretName != ""
+			passthrough = tls.SecretName == "" && tls.Passthrough
+		}
+		if ir.Spec.TCPProxy != nil && (enforceTLS || passthrough) {
+			if route, ok := ingressRouteTCPProxy(nodes, ir, host, nil); ok {
+				routes = append(routes, route)
+			}
+		}
+		routes = append(routes, ingressRouteRoutes(nodes, ir, "", host, ir.Spec.TCPProxy == nil && enforceTLS, nil)...)
+	}
+
+	sortRoutes(routes)
+	return routes
+}
+
+func ingressRouteRoutes(nodes map[key]*irv1beta1.IngressRoute, ir *irv1beta1.IngressRoute, prefixMatch string, host string, enforceTLS bool, visited []*irv1beta1.IngressRoute) []Route {
+
+	visited = append(visited, ir)
+
+	var routes []Route
+	for _, irRoute := range ir.Spec.Routes {
+		if len(irRoute.Services) > 0 && irRoute.Delegate != nil {
+			return routes
+		}
+
+		if len(irRoute.Services) > 0 {
+			if !matchesPathPrefix(irRoute.Match, prefixMatch) {
+				return routes
+			}
+			route := Route{
+				Host:          host,
+				Prefix:        irRoute.Match,
+				Secure:        enforceTLS,
+				HTTPSRedirect: enforceTLS && !irRoute.PermitInsecure,
+				PrefixRewrite: irRoute.PrefixRewrite,
+				RetryPolicy:   retryPolicy(irRoute.RetryPolicy),
+			}
+			if irRoute.TimeoutPolicy != nil {
+				route.ResponseTimeout = parseTimeout(irRoute.TimeoutPolicy.Request)
+			}
+			for _, service := range irRoute.Services {
+				route.Services = append(route.Services, Service{
+					Namespace: ir.Namespace,
+					Name:      service.Name,
+					Port:      service.Port,
+					Weight:    service.Weight,
+				})
+			}
+			sortServices(route.Services)
+			routes = append(routes, route)
+			continue
+		}
+
+		if irRoute.Delegate == nil {
+			continue
+		}
+		child, ok := nodes[delegateKey(ir.Namespace, irRoute.Delegate)]
+		if !ok {
+			continue
+		}
+		if child.Spec.VirtualHost != nil {
+			return routes
+		}
+		if ingressRouteVisited(visited, child) {
+			return routes
+		}
+		routes = append(routes, ingressRouteRoutes(nodes, child, irRoute.Match, host, enforceTLS, visited)...)
+	}
+
+	return routes
+}
+
+func ingressRouteTCPProxy(nodes map[key]*irv1beta1.IngressRoute, ir *irv1beta1.IngressRoute, host string, visited []*irv1beta1.IngressRoute) (Route, bool) {
+
+	visited = append(visited, ir)
+
+	tcpproxy := ir.Spec.TCPProxy
+	if tcpproxy == nil {
+		return Route{}, false
+	}
+	if len(tcpproxy.Services) > 0 && tcpproxy.Delegate != nil {
+		return Route{}, false
+	}
+
+	if len(tcpproxy.Services) > 0 {
+		route := Route{
+			Host:     host,
+			TCPProxy: true,
+			Secure:   true,
+		}
+		for _, service := range tcpproxy.Services {
+			route.Services = append(route.Services, Service{
+				Namespace: ir.Namespace,
+				Name:      service.Name,
+				Port:      service.Port,
+			})
+		}
+		sortServices(route.Services)
+		return route, true
+	}
+
+	if tcpproxy.Delegate == nil {
+		return Route{}, false
+	}
+	child, ok := nodes[delegateKey(ir.Namespace, tcpproxy.Delegate)]
+	if !ok || ingressRouteVisited(visited, child) {
+		return Route{}, false
+	}
+	return ingressRouteTCPProxy(nodes, child, host, visited)
+}
+
+func delegateKey(namespace string, delegate *irv1beta1.Delegate) key {
+	if delegate.Namespace != "" {
+		namespace = delegate.Namespace
+	}
+	return key{namespace: namespace, name: delegate.Name}
+}
+
+func ingressRouteVisited(visited []*irv1beta1.IngressRoute, ir *irv1beta1.IngressRoute) bool {
+	for _, v := range visited {
+		if v.Namespace == ir.Namespace && v.Name == ir.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// FromHTTPProxies returns the routes of the root HTTPProxies in hps, following
+// includes to the other HTTPProxies in hps, sorted by host and prefix.
+// Like Contour, it stops processing an HTTPProxy at an include of a root HTTPProxy,
+// and doesn't follow include cycles.
+func FromHTTPProxies(hps []*hpv1.HTTPProxy) []Route {
+
+	nodes := make(map[key]*hpv1.HTTPProxy)
+	for _, hp := range hps {
+		nodes[key{namespace: hp.Namespace, name: hp.Name}] = hp
+	}
+
+	var routes []Route
+	for _, hp := range hps {
+		if hp.Spec.VirtualHost == nil {
+			continue
+		}
+		host := hp.Spec.VirtualHost.Fqdn
+		// TLS is valid with either a secret or passthrough.
+		tlsValid := false
+		if tls := hp.Spec.VirtualHost.TLS; tls != nil {
+			tlsValid = tls.Passthrough != (tls.SecretName != "")
+		}
+		if hp.Spec.TCPProxy != nil {
+			if !tlsValid {
+				continue
+			}
+			route, ok := httpProxyTCPProxy(nodes, hp, host, nil)
+			if !ok {
+				continue
+			}
+			routes = append(routes, route)
+		}
+		secure := tlsValid && hp.Spec.TCPProxy == nil
+		routes = append(routes, httpProxyRoutes(nodes, hp, nil, host, tlsValid, secure, nil)...)
+	}
+
+	sortRoutes(routes)
+	return routes
+}
+
+func httpProxyRoutes(nodes map[key]*hpv1.HTTPProxy, hp *hpv1.HTTPProxy, conditions []hpv1.Condition, host string, enforceTLS bool, secure bool, visited []*hpv1.HTTPProxy) []Route {
+
+	if httpProxyVisited(visited, hp) {
+		return nil
+	}
+	visited = append(visited, hp)
+
+	var routes []Route
+	for _, include := range hp.Spec.Includes {
+		child, ok := nodes[includeKey(hp.Namespace, include.Namespace, include.Name)]
+		if !ok || child.Spec.VirtualHost != nil {
+			return nil
+		}
+		// Copy the conditions, so that includes don't share them.
+		childConditions := append(append([]hpv1.Condition{}, conditions...), include.Conditions...)
+		routes = append(routes, httpProxyRoutes(nodes, child, childConditions, host, enforceTLS, secure, visited)...)
+	}
+
+	for _, hpRoute := range hp.Spec.Routes {
+		route := Route{
+			Host:          host,
+			Prefix:        mergePathConditions(append(append([]hpv1.Condition{}, conditions...), hpRoute.Conditions...)),
+			Secure:        secure,
+			HTTPSRedirect: enforceTLS && !hpRoute.PermitInsecure,
+			RetryPolicy:   retryPolicy(hpRoute.RetryPolicy),
+		}
+		if hpRoute.TimeoutPolicy != nil {
+			route.ResponseTimeout = parseTimeout(hpRoute.TimeoutPolicy.Response)
+		}
+		if hpRoute.PathRewritePolicy != nil {
+			route.PrefixRewrite = prefixRewrite(route.Prefix, hpRoute.PathRewritePolicy.ReplacePrefix)
+		}
+		for _, service := range hpRoute.Services {
+			// A mirror only gets a copy of the requests.
+			if service.Mirror {
+				continue
+			}
+			route.Services = append(route.Services, Service{
+				Namespace: hp.Namespace,
+				Name:      service.Name,
+				Port:      service.Port,
+				Weight:    uint32(service.Weight),
+			})
+		}
+		sortServices(route.Services)
+		routes = append(routes, route)
+	}
+
+	return ExpandPrefixRewrites(routes)
+}
+
+// ExpandPrefixRewrites adds the routes Contour adds for HTTPProxy prefix rewrites,
+// so that a rewrite applies the same way with and without a trailing '/'.
+// A prefix of /foo rewritten to /bar becomes /foo rewritten to /bar, and /foo/
+// rewritten to /bar/, unless there are already routes for both.
+// The routes from FromHTTPProxies already have them.
+func ExpandPrefixRewrites(routes []Route) []Route {
+
+	var prefixes []string
+	groups := make(map[string][]int)
+	for index, route := range routes {
+		prefix := route.Prefix
+		if prefix != "/" {
+			prefix = strings.TrimRight(prefix, "/")
+		}
+		if _, ok := groups[prefix]; !ok {
+			prefixes = append(prefixes, prefix)
+		}
+		groups[prefix] = append(groups[prefix], index)
+	}
+
+	for _, prefix := range prefixes {
+		group := groups[prefix]
+		if len(group) != 1 {
+			continue
+		}
+		route := &routes[group[0]]
+		if route.PrefixRewrite == "" || route.Prefix == "/" {
+			continue
+		}
+
+		slashed := *route
+		route.Prefix = prefix
+		route.PrefixRewrite = strings.TrimRight(route.PrefixRewrite, "/")
+		slashed.Prefix = prefix + "/"
+		slashed.PrefixRewrite = route.PrefixRewrite + "/"
+		// There's no empty rewrite, it's the same as rewriting to '/'.
+		if route.PrefixRewrite == "" {
+			route.PrefixRewrite = "/"
+		}
+		routes = append(routes, slashed)
+	}
+
+	return routes
+}
+
+func httpProxyTCPProxy(nodes map[key]*hpv1.HTTPProxy, hp *hpv1.HTTPProxy, host string, visited []*hpv1.HTTPProxy) (Route, bool) {
+
+	tcpproxy := hp.Spec.TCPProxy
+	if tcpproxy == nil || httpProxyVisited(visited, hp) {
+		return Route{}, false
+	}
+	visited = append(visited, hp)
+
+	if len(tcpproxy.Services) > 0 && tcpproxy.Include != nil {
+		return Route{}, false
+	}
+
+	if len(tcpproxy.Services) > 0 {
+		route := Route{
+			Host:     host,
+			TCPProxy: true,
+			Secure:   true,
+		}
+		for _, service := range tcpproxy.Services {
+			route.Services = append(route.Services, Service{
+				Namespace: hp.Namespace,
+				Name:      service.Name,
+				Port:      service.Port,
+			})
+		}
+		sortServices(route.Services)
+		return route, true
+	}
+
+	if tcpproxy.Include == nil {
+		return Route{}, false
+	}
+	child, ok := nodes[includeKey(hp.Namespace, tcpproxy.Include.Namespace, tcpproxy.Include.Name)]
+	if !ok || child.Spec.VirtualHost != nil {
+		return Route{}, false
+	}
+	return httpProxyTCPProxy(nodes, child, host, visited)
+}
+
+func includeKey(parentNamespace string, namespace string, name string) key {
+	if namespace == "" {
+		namespace = parentNamespace
+	}
+	return key{namespace: namespace, name: name}
+}
+
+func httpProxyVisited(visited []*hpv1.HTTPProxy, hp *hpv1.HTTPProxy) bool {
+	for _, v := range visited {
+		if v.Namespace == hp.Namespace && v.Name == hp.Name {
+			return true
+		}
+	}
+	return false
+}
+
+var multipleSlashes = regexp.MustCompile(`//+`)
+
+// mergePathConditions joins the prefixes of a route's conditions, and the
+// conditions of the includes that lead to it, the way Contour does.
+func mergePathConditions(conditions []hpv1.Condition) string {
+	prefix := ""
+	for _, condition := range conditions {
+		prefix += condition.Prefix
+	}
+	prefix = multipleSlashes.ReplaceAllString(prefix, "/")
+	if prefix == "" {
+		return "/"
+	}
+	return prefix
+}
+
+// prefixRewrite returns the replacement for a route's prefix. A replacement
+// for the exact prefix takes precedence over one for any prefix.
+func prefixRewrite(prefix string, replacements []hpv1.ReplacePrefix) string {
+	for _, replacement := range replacements {
+		if replacement.Prefix != "" && replacement.Prefix == prefix {
+			return replacement.Replacement
+		}
+	}
+	for _, replacement := range replacements {
+		if replacement.Prefix == "" {
+			return replacement.Replacement
+		}
+	}
+	return ""
+}
+
+// matchesPathPrefix returns true if path is under prefix, by whole path segments.
+func matchesPathPrefix(path, prefix string) bool {
+	if len(prefix) == 0 {
+		return true
+	}
+	if len(path) == 0 {
+		return false
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return strings.HasPrefix(path, prefix)
+}
+
+func retryPolicy(policy *hpv1.RetryPolicy) *RetryPolicy {
+	if policy == nil {
+		return nil
+	}
+	// Contour ignores a perTryTimeout it can't parse or that's negative, and
+	// always retries at least once.
+	perTryTimeout, _ := time.ParseDuration(policy.PerTryTimeout)
+	if perTryTimeout < 0 {
+		perTryTimeout = 0
+	}
+	numRetries := policy.NumRetries
+	if numRetries < 1 {
+		numRetries = 1
+	}
+	return &RetryPolicy{
+		NumRetries:    numRetries,
+		PerTryTimeout: perTryTimeout,
+	}
+}
+
+// parseTimeout parses a timeout the way Contour does. Zero means the default
+// timeout, and -1 means no timeout.
+func parseTimeout(timeout string) time.Duration {
+	if timeout == "" {
+		return 0
+	}
+	if timeout == "infinity" {
+		return -1
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return -1
+	}
+	return duration
+}
+
+func sortServices(services []Service) {
+	sort.SliceStable(services, func(i, j int) bool {
+		a, b := services[i], services[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Port < b.Port
+	})
+}
+
+func sortRoutes(routes []Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.TCPProxy != b.TCPProxy {
+			return a.TCPProxy
+		}
+		return a.Prefix < b.Prefix
+	})
+}
@@ -0,0 +1,460 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+)
+
+func TestFromIngressRoutes(t *testing.T) {
+
+	tests := map[string]struct {
+		inputs []string
+		want   []Route
+	}{
+		"nested delegation": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - match: /
+    timeoutPolicy:
+      request: infinity
+    services:
+    - name: s2
+      port: 80
+      weight: 10
+    - name: s1
+      port: 80
+      weight: 90
+  - match: /blog
+    delegate:
+      name: blog
+      namespace: blog
+`, `
+metadata:
+  name: blog
+  namespace: blog
+spec:
+  routes:
+  - match: /blog
+    prefixRewrite: /
+    retryPolicy:
+      count: 0
+      perTryTimeout: invalid
+    services:
+    - name: blog
+      port: 8080
+`},
+			want: []Route{
+				{
+					Host:            "foo.bar.com",
+					Prefix:          "/",
+					Services:        []Service{{Namespace: "default", Name: "s1", Port: 80, Weight: 90}, {Namespace: "default", Name: "s2", Port: 80, Weight: 10}},
+					ResponseTimeout: -1,
+				},
+				{
+					Host:          "foo.bar.com",
+					Prefix:        "/blog",
+					Services:      []Service{{Namespace: "blog", Name: "blog", Port: 8080}},
+					PrefixRewrite: "/",
+					RetryPolicy:   &RetryPolicy{NumRetries: 1},
+				},
+			},
+		},
+		"match outside the delegation prefix": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - match: /blog
+    delegate:
+      name: blog
+`, `
+metadata:
+  name: blog
+  namespace: default
+spec:
+  routes:
+  - match: /blog/a
+    services:
+    - name: a
+      port: 80
+  - match: /blogb
+    services:
+    - name: b
+      port: 80
+  - match: /blog/c
+    services:
+    - name: c
+      port: 80
+`},
+			want: []Route{
+				{Host: "foo.bar.com", Prefix: "/blog/a", Services: []Service{{Namespace: "default", Name: "a", Port: 80}}},
+			},
+		},
+		"delegation cycle": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - match: /a
+    delegate:
+      name: a
+`, `
+metadata:
+  name: a
+  namespace: default
+spec:
+  routes:
+  - match: /a
+    services:
+    - name: a
+      port: 80
+  - match: /a/b
+    delegate:
+      name: a
+`},
+			want: []Route{
+				{Host: "foo.bar.com", Prefix: "/a", Services: []Service{{Namespace: "default", Name: "a", Port: 80}}},
+			},
+		},
+		"tls": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+    tls:
+      secretName: secret
+  routes:
+  - match: /
+    services:
+    - name: s1
+      port: 80
+  - match: /insecure
+    permitInsecure: true
+    services:
+    - name: s2
+      port: 80
+`},
+			want: []Route{
+				{Host: "foo.bar.com", Prefix: "/", Secure: true, HTTPSRedirect: true, Services: []Service{{Namespace: "default", Name: "s1", Port: 80}}},
+				{Host: "foo.bar.com", Prefix: "/insecure", Secure: true, Services: []Service{{Namespace: "default", Name: "s2", Port: 80}}},
+			},
+		},
+		"tcpproxy delegation": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+    tls:
+      passthrough: true
+  tcpproxy:
+    delegate:
+      name: tcp
+`, `
+metadata:
+  name: tcp
+  namespace: default
+spec:
+  tcpproxy:
+    services:
+    - name: tls
+      port: 443
+      weight: 20
+`},
+			want: []Route{
+				{Host: "foo.bar.com", TCPProxy: true, Secure: true, Services: []Service{{Namespace: "default", Name: "tls", Port: 443}}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var irs []*irv1beta1.IngressRoute
+			for _, input := range tc.inputs {
+				ir := &irv1beta1.IngressRoute{}
+				if err := yaml.Unmarshal([]byte(input), ir); err != nil {
+					t.Fatal(err)
+				}
+				irs = append(irs, ir)
+			}
+			if diff := cmp.Diff(FromIngressRoutes(irs), tc.want); diff != "" {
+				t.Fatalf("Routes mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestFromHTTPProxies(t *testing.T) {
+
+	tests := map[string]struct {
+		inputs []string
+		want   []Route
+	}{
+		"nested includes": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  includes:
+  - name: blog
+    namespace: blog
+    conditions:
+    - prefix: /blog/
+  routes:
+  - timeoutPolicy:
+      response: 1s
+    services:
+    - name: s1
+      port: 80
+`, `
+metadata:
+  name: blog
+  namespace: blog
+spec:
+  includes:
+  - name: admin
+    conditions:
+    - prefix: /admin
+  routes:
+  - services:
+    - name: blog
+      port: 8080
+`, `
+metadata:
+  name: admin
+  namespace: blog
+spec:
+  routes:
+  - conditions:
+    - prefix: /
+    retryPolicy:
+      count: 3
+      perTryTimeout: 150ms
+    services:
+    - name: admin
+      port: 80
+`},
+			want: []Route{
+				{
+					Host:            "foo.bar.com",
+					Prefix:          "/",
+					Services:        []Service{{Namespace: "default", Name: "s1", Port: 80}},
+					ResponseTimeout: time.Second,
+				},
+				{
+					Host:     "foo.bar.com",
+					Prefix:   "/blog/",
+					Services: []Service{{Namespace: "blog", Name: "blog", Port: 8080}},
+				},
+				{
+					Host:        "foo.bar.com",
+					Prefix:      "/blog/admin/",
+					Services:    []Service{{Namespace: "blog", Name: "admin", Port: 80}},
+					RetryPolicy: &RetryPolicy{NumRetries: 3, PerTryTimeout: 150 * time.Millisecond},
+				},
+			},
+		},
+		"missing include": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  includes:
+  - name: missing
+  routes:
+  - services:
+    - name: s1
+      port: 80
+`},
+			want: nil,
+		},
+		"prefix rewrites": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  includes:
+  - name: child
+    conditions:
+    - prefix: /foo
+`, `
+metadata:
+  name: child
+  namespace: default
+spec:
+  routes:
+  - pathRewritePolicy:
+      replacePrefix:
+      - replacement: /any
+      - prefix: /foo
+        replacement: /exact
+    services:
+    - name: s1
+      port: 80
+  - conditions:
+    - prefix: /bar
+    pathRewritePolicy:
+      replacePrefix:
+      - prefix: /foo
+        replacement: /exact
+      - replacement: /any
+    services:
+    - name: s2
+      port: 80
+`},
+			want: []Route{
+				{Host: "foo.bar.com", Prefix: "/foo", Services: []Service{{Namespace: "default", Name: "s1", Port: 80}}, PrefixRewrite: "/exact"},
+				{Host: "foo.bar.com", Prefix: "/foo/", Services: []Service{{Namespace: "default", Name: "s1", Port: 80}}, PrefixRewrite: "/exact/"},
+				{Host: "foo.bar.com", Prefix: "/foo/bar", Services: []Service{{Namespace: "default", Name: "s2", Port: 80}}, PrefixRewrite: "/any"},
+				{Host: "foo.bar.com", Prefix: "/foo/bar/", Services: []Service{{Namespace: "default", Name: "s2", Port: 80}}, PrefixRewrite: "/any/"},
+			},
+		},
+		"tls with tcpproxy": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+    tls:
+      passthrough: true
+  tcpproxy:
+    services:
+    - name: tls
+      port: 443
+  routes:
+  - services:
+    - name: s1
+      port: 80
+`},
+			want: []Route{
+				{Host: "foo.bar.com", TCPProxy: true, Secure: true, Services: []Service{{Namespace: "default", Name: "tls", Port: 443}}},
+				{Host: "foo.bar.com", Prefix: "/", HTTPSRedirect: true, Services: []Service{{Namespace: "default", Name: "s1", Port: 80}}},
+			},
+		},
+		"tcpproxy include": {
+			inputs: []string{`
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+    tls:
+      secretName: secret
+  tcpproxy:
+    includes:
+      name: tcp
+`, `
+metadata:
+  name: tcp
+  namespace: default
+spec:
+  tcpproxy:
+    services:
+    - name: tls
+      port: 443
+`},
+			want: []Route{
+				{Host: "foo.bar.com", TCPProxy: true, Secure: true, Services: []Service{{Namespace: "default", Name: "tls", Port: 443}}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var hps []*hpv1.HTTPProxy
+			for _, input := range tc.inputs {
+				hp := &hpv1.HTTPProxy{}
+				if err := yaml.Unmarshal([]byte(input), hp); err != nil {
+					t.Fatal(err)
+				}
+				hps = append(hps, hp)
+			}
+			if diff := cmp.Diff(FromHTTPProxies(hps), tc.want); diff != "" {
+				t.Fatalf("Routes mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+
+	tests := map[string]struct {
+		policy *hpv1.RetryPolicy
+		want   *RetryPolicy
+	}{
+		"no policy": {},
+		"valid": {
+			policy: &hpv1.RetryPolicy{NumRetries: 3, PerTryTimeout: "150ms"},
+			want:   &RetryPolicy{NumRetries: 3, PerTryTimeout: 150 * time.Millisecond},
+		},
+		"invalid perTryTimeout": {
+			policy: &hpv1.RetryPolicy{NumRetries: 2, PerTryTimeout: "infinity"},
+			want:   &RetryPolicy{NumRetries: 2},
+		},
+		"negative perTryTimeout": {
+			policy: &hpv1.RetryPolicy{NumRetries: 2, PerTryTimeout: "-1s"},
+			want:   &RetryPolicy{NumRetries: 2},
+		},
+		"no retries": {
+			policy: &hpv1.RetryPolicy{},
+			want:   &RetryPolicy{NumRetries: 1},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(retryPolicy(tc.policy), tc.want); diff != "" {
+				t.Fatalf("RetryPolicy mismatch:\n%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/routing"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// equivalenceHost is the virtual host that nonroot fixtures are delegated from.
+const equivalenceHost = "equivalence.example.com"
+
+// TestTranslationEquivalence checks that the HTTPProxy translated from each
+// IngressRoute fixture routes the same paths to the same services, with the
+// same timeouts, retries and rewrites, as the IngressRoute.
+func TestTranslationEquivalence(t *testing.T) {
+	for name, tc := range buildFixtureSet(t) {
+		t.Run(name, func(t *testing.T) {
+			items, err := k8sdecoder.Decode(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ir, ok := items[0].Object.(*irv1beta1.IngressRoute)
+			if !ok {
+				t.Skipf("input is a %s, not an IngressRoute", items[0].GroupVersionKind)
+			}
+
+			hp, _, err := IngressRouteToHTTPProxy(ir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			irs := []*irv1beta1.IngressRoute{ir}
+			hps := []*hpv1.HTTPProxy{hp}
+			// Contour drops an HTTPProxy that includes a missing one, but not an
+			// IngressRoute, so stand in for the delegates outside the fixture.
+			for _, route := range ir.Spec.Routes {
+				if route.Delegate == nil {
+					continue
+				}
+				meta := delegateMeta(ir.Namespace, route.Delegate)
+				irs = append(irs, &irv1beta1.IngressRoute{ObjectMeta: meta})
+				hps = append(hps, &hpv1.HTTPProxy{ObjectMeta: meta})
+			}
+			// A tcpproxy delegate has no route of its own, so give the stand-in
+			// a service to proxy to. It's translated along with the fixture,
+			// since only a tcpproxy delegation makes it valid.
+			if ir.Spec.TCPProxy != nil && ir.Spec.TCPProxy.Delegate != nil {
+				child := &irv1beta1.IngressRoute{
+					ObjectMeta: delegateMeta(ir.Namespace, ir.Spec.TCPProxy.Delegate),
+					Spec: irv1beta1.IngressRouteSpec{
+						TCPProxy: &irv1beta1.TCPProxy{Services: []irv1beta1.Service{{Name: "tcp", Port: 443}}},
+					},
+				}
+				translation := IngressRoutesToHTTPProxies([]*irv1beta1.IngressRoute{ir, child})[1]
+				if translation.Err != nil {
+					t.Fatal(translation.Err)
+				}
+				irs = append(irs, child)
+				hps = append(hps, translation.HTTPProxy)
+			}
+			if ir.Spec.VirtualHost == nil {
+				// A nonroot object has no routes on its own, so delegate to it
+				// from a root. The prefix it's delegated at is recorded with the
+				// fixture, rather than guessed, so that a wrong guess by the
+				// translation shows up as a difference.
+				data, err := ioutil.ReadFile(fmt.Sprintf("testdata/%s/includeprefix.txt", name))
+				if err != nil {
+					t.Fatalf("testdata/%s/includeprefix.txt must be present for a nonroot IngressRoute, %s", name, err)
+				}
+				irRoot, hpRoot := equivalenceRoots(ir.Namespace, ir.Name, strings.TrimSpace(string(data)))
+				irs = append(irs, irRoot)
+				hps = append(hps, hpRoot)
+			}
+
+			assertEquivalent(t, irs, hps)
+		})
+	}
+}
+
+// TestSetTranslationEquivalence checks that delegation trees translate to
+// HTTPProxies that route the same way, which depends on the include prefixes
+// being trimmed from every level of the tree, and on a tcpproxy being kept in
+// the IngressRoute a tcpproxy delegates to.
+func TestSetTranslationEquivalence(t *testing.T) {
+
+	tests := map[string][]string{
+		"nested delegation":   {setRoot, setService2, setAPI},
+		"tcpproxy delegation": {setTCPProxyRoot, setTCPProxyChild},
+	}
+
+	for name, inputs := range tests {
+		t.Run(name, func(t *testing.T) {
+			var irs []*irv1beta1.IngressRoute
+			for _, input := range inputs {
+				ir, err := k8sdecoder.DecodeIngressRoute([]byte(input))
+				if err != nil {
+					t.Fatal(err)
+				}
+				irs = append(irs, ir)
+			}
+
+			var hps []*hpv1.HTTPProxy
+			for _, translation := range IngressRoutesToHTTPProxies(irs) {
+				if translation.Err != nil {
+					t.Fatal(translation.Err)
+				}
+				hps = append(hps, translation.HTTPProxy)
+			}
+
+			assertEquivalent(t, irs, hps)
+		})
+	}
+}
+
+func assertEquivalent(t *testing.T, irs []*irv1beta1.IngressRoute, hps []*hpv1.HTTPProxy) {
+	t.Helper()
+
+	// Contour adds a route for /foo/ to an HTTPProxy that rewrites the
+	// prefix /foo, so that /foo/x isn't rewritten to //x like it is for an
+	// IngressRoute. The translation changes that on purpose, so expect it.
+	want := routing.ExpandPrefixRewrites(routing.FromIngressRoutes(irs))
+	if len(want) == 0 {
+		t.Fatal("the IngressRoutes have no routes to compare")
+	}
+	if diff := cmp.Diff(routing.FromHTTPProxies(hps), want); diff != "" {
+		t.Fatalf("The HTTPProxies route differently to the IngressRoutes:\n%v", diff)
+	}
+}
+
+// delegateMeta returns the name and namespace of the object a delegate in
+// namespace refers to.
+func delegateMeta(namespace string, delegate *irv1beta1.Delegate) v1.ObjectMeta {
+	meta := v1.ObjectMeta{Name: delegate.Name, Namespace: delegate.Namespace}
+	if meta.Namespace == "" {
+		meta.Namespace = namespace
+	}
+	return meta
+}
+
+// equivalenceRoots returns a root IngressRoute that delegates to an IngressRoute
+// at includePrefix, and the matching root HTTPProxy.
+func equivalenceRoots(namespace string, name string, includePrefix string) (*irv1beta1.IngressRoute, *hpv1.HTTPProxy) {
+
+	meta := v1.ObjectMeta{
+		Name:      "equivalence-root",
+		Namespace: namespace,
+	}
+
+	irRoot := &irv1beta1.IngressRoute{
+		ObjectMeta: meta,
+		Spec: irv1beta1.IngressRouteSpec{
+			VirtualHost: &hpv1.VirtualHost{Fqdn: equivalenceHost},
+			Routes: []irv1beta1.Route{{
+				Match:    includePrefix,
+				Delegate: &irv1beta1.Delegate{Name: name},
+			}},
+		},
+	}
+
+	include := hpv1.Include{Name: name}
+	if includePrefix != "/" {
+		include.Conditions = []hpv1.Condition{{Prefix: includePrefix}}
+	}
+	hpRoot := &hpv1.HTTPProxy{
+		ObjectMeta: meta,
+		Spec: hpv1.HTTPProxySpec{
+			VirtualHost: &hpv1.VirtualHost{Fqdn: equivalenceHost},
+			Includes:    []hpv1.Include{include},
+		},
+	}
+
+	return irRoot, hpRoot
+}
@@ -0,0 +1,79 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package translator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+)
+
+// FuzzLongestCommonPathPrefix takes the paths as a single string, one per line.
+// The seed corpus is the matches of each IngressRoute test case.
+func FuzzLongestCommonPathPrefix(f *testing.F) {
+
+	for _, tc := range buildFixtureSet(f) {
+		ir, err := k8sdecoder.DecodeIngressRoute(tc.input)
+		if err != nil {
+			continue
+		}
+		f.Add(strings.Join(extractPrefixes(ir.Spec.Routes), "\n"))
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		paths := strings.Split(input, "\n")
+		prefix := longestCommonPathPrefix(append([]string(nil), paths...))
+		if prefix == "" {
+			return
+		}
+		for _, path := range paths {
+			if !strings.HasPrefix(withLeadingSlash(path), withLeadingSlash(prefix)) {
+				t.Fatalf("%q is not a prefix of %q", prefix, path)
+			}
+		}
+	})
+}
+
+// FuzzIngressRouteToHTTPProxy decodes and translates an IngressRoute.
+// The seed corpus is the input.yaml of each test case.
+func FuzzIngressRouteToHTTPProxy(f *testing.F) {
+
+	for _, tc := range buildFixtureSet(f) {
+		f.Add(tc.input)
+	}
+
+	f.Fuzz(func(t *testing.T, input []byte) {
+		ir, err := k8sdecoder.DecodeIngressRoute(input)
+		if err != nil {
+			return
+		}
+		hp, _, err := IngressRouteToHTTPProxy(ir)
+		if err == nil && hp == nil {
+			t.Fatal("no HTTPProxy and no error")
+		}
+	})
+}
+
+// withLeadingSlash adds a '/' to the start of a path that doesn't have one,
+// since longestCommonPathPrefix treats "foo" the same as "/foo".
+func withLeadingSlash(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return path
+	}
+	return "/" + path
+}
@@ -0,0 +1,352 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Annotations on an Ingress that are translated into HTTPProxy fields, and
+// so aren't copied to the HTTPProxy.
+const (
+	annotationForceSSLRedirect = "ingress.kubernetes.io/force-ssl-redirect"
+	annotationAllowHTTP        = "kubernetes.io/ingress.allow-http"
+)
+
+// compatAnnotations are the Contour annotations that can have either the
+// projectcontour.io/ or the contour.heptio.com/ prefix.
+var compatAnnotations = []string{
+	"response-timeout",
+	"request-timeout",
+	"retry-on",
+	"num-retries",
+	"per-try-timeout",
+	"websocket-routes",
+	"tls-minimum-protocol-version",
+}
+
+// IngressToHTTPProxies translates an Ingress into a root HTTPProxy for each
+// host in its rules, emitting warnings as it goes.
+// The Contour annotations on the Ingress are translated into the matching
+// HTTPProxy fields, with a warning for any that can't be represented.
+func IngressToHTTPProxies(ing *netv1beta1.Ingress) ([]*hpv1.HTTPProxy, []warning.Warning, error) {
+
+	var warnings []warning.Warning
+
+	// Group the paths by host, keeping the hosts in the order they're seen.
+	var hosts []string
+	// Contour adds the default backend as a rule with no host.
+	noHost := ing.Spec.Backend != nil
+	paths := make(map[string][]netv1beta1.HTTPIngressPath)
+	for index, rule := range ing.Spec.Rules {
+		switch {
+		case rule.Host == "":
+			noHost = true
+			continue
+		case strings.Contains(rule.Host, "*"):
+			warnings = append(warnings, warning.New(warning.SeverityInfo, warning.IngressWildcardHost, fmt.Sprintf(".spec.rules[%d].host", index),
+				"Rules for wildcard host %s could not be translated, and were ignored by Contour. Please check if they're needed.", rule.Host))
+			continue
+		}
+		if _, ok := paths[rule.Host]; !ok {
+			hosts = append(hosts, rule.Host)
+			paths[rule.Host] = nil
+		}
+		if rule.HTTP != nil {
+			paths[rule.Host] = append(paths[rule.Host], rule.HTTP.Paths...)
+		}
+	}
+
+	if noHost {
+		warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IngressNoHost, ".spec.rules",
+			"Rules without a host, including the default backend, could not be translated, HTTPProxy requires an fqdn. Please add a host, or translate these rules by hand."))
+	}
+
+	if len(hosts) == 0 {
+		return nil, nil, fmt.Errorf("can't translate Ingress %s/%s: no rules have a host", ing.Namespace, ing.Name)
+	}
+
+	// Contour uses the last secret for a host.
+	secrets := make(map[string]string)
+	for _, tls := range ing.Spec.TLS {
+		for _, host := range tls.Hosts {
+			secrets[host] = tls.SecretName
+		}
+	}
+
+	timeoutPolicy, timeoutWarnings := ingressTimeoutPolicy(ing)
+	warnings = append(warnings, timeoutWarnings...)
+
+	retryPolicy, retryWarnings := ingressRetryPolicy(ing)
+	warnings = append(warnings, retryWarnings...)
+
+	websocketRoutes := ingressWebsocketRoutes(ing)
+
+	forceSSLRedirect := ing.Annotations[annotationForceSSLRedirect] == "true"
+	allowHTTP := ing.Annotations[annotationAllowHTTP] != "false"
+
+	var proxies []*hpv1.HTTPProxy
+	for _, host := range hosts {
+
+		virtualHost := &hpv1.VirtualHost{
+			Fqdn: host,
+		}
+
+		// In Contour, the routes of an Ingress are served over HTTP too, unless
+		// the Ingress says otherwise. HTTPProxy redirects them to HTTPS by default.
+		secretName, tls := secrets[host]
+		permitInsecure := false
+		switch {
+		case tls && !forceSSLRedirect && allowHTTP:
+			permitInsecure = true
+		case tls && !forceSSLRedirect && !allowHTTP:
+			warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IngressAllowHTTP, annotationPath(annotationAllowHTTP),
+				"%s: false on host %s could not be applied, HTTPProxy can't turn off HTTP. HTTP requests will be redirected to HTTPS instead.", annotationAllowHTTP, host))
+		case !tls && forceSSLRedirect:
+			warnings = append(warnings, warning.New(warning.SeverityInfo, warning.IngressForceSSLRedirectNoTLS, annotationPath(annotationForceSSLRedirect),
+				"%s: true on host %s has no TLS, discarding. Please check if TLS is needed.", annotationForceSSLRedirect, host))
+		case !tls && !allowHTTP:
+			warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IngressAllowHTTPNoTLS, annotationPath(annotationAllowHTTP),
+				"%s: false on host %s has no TLS, so Contour served nothing for it. HTTPProxy will serve it over HTTP. Please check if it's needed.", annotationAllowHTTP, host))
+		}
+
+		if tls {
+			virtualHost.TLS = &hpv1.TLS{
+				SecretName:             secretName,
+				MinimumProtocolVersion: compatAnnotation(ing, "tls-minimum-protocol-version"),
+			}
+		}
+
+		var routes []hpv1.Route
+		for _, path := range paths[host] {
+			route, err := translateIngressPath(path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("can't translate Ingress %s/%s: %s", ing.Namespace, ing.Name, err)
+			}
+			route.EnableWebsockets = websocketRoutes[ingressPath(path)]
+			route.PermitInsecure = permitInsecure
+			route.TimeoutPolicy = timeoutPolicy.DeepCopy()
+			route.RetryPolicy = retryPolicy.DeepCopy()
+			routes = append(routes, route)
+		}
+
+		name := ing.Name
+		if len(hosts) > 1 {
+			name = fmt.Sprintf("%s-%s", ing.Name, host)
+		}
+
+		proxies = append(proxies, &hpv1.HTTPProxy{
+			TypeMeta: v1.TypeMeta{
+				Kind:       "HTTPProxy",
+				APIVersion: "projectcontour.io/v1",
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Name:        name,
+				Namespace:   ing.Namespace,
+				Labels:      ing.ObjectMeta.DeepCopy().GetLabels(),
+				Annotations: untranslatedAnnotations(ing.Annotations),
+			},
+			Spec: hpv1.HTTPProxySpec{
+				VirtualHost: virtualHost,
+				Routes:      routes,
+			},
+		})
+	}
+
+	object := warning.Object{Kind: "Ingress", Namespace: ing.Namespace, Name: ing.Name}
+	return proxies, warning.WithObject(warnings, object), nil
+}
+
+// translateIngressPath translates a single path of an Ingress rule into a route.
+func translateIngressPath(path netv1beta1.HTTPIngressPath) (hpv1.Route, error) {
+
+	prefix := ingressPath(path)
+	// Contour treats a path that looks like a regex as one. A regex that only
+	// matches a prefix can be translated.
+	if strings.ContainsAny(prefix, "^+*[]%") {
+		trimmed := strings.TrimSuffix(prefix, ".*")
+		if strings.ContainsAny(trimmed, `.^$+*?[](){}|\%`) {
+			return hpv1.Route{}, fmt.Errorf("path %s is a regular expression, HTTPProxy only supports prefix conditions", prefix)
+		}
+		prefix = trimmed
+		if prefix == "" {
+			prefix = "/"
+		}
+	}
+
+	if path.Backend.ServicePort.Type == intstr.String {
+		return hpv1.Route{}, fmt.Errorf("servicePort %s on service %s is a port name, HTTPProxy services need a port number", path.Backend.ServicePort.StrVal, path.Backend.ServiceName)
+	}
+
+	return hpv1.Route{
+		Conditions: []hpv1.Condition{
+			hpv1.Condition{
+				Prefix: prefix,
+			},
+		},
+		Services: []hpv1.Service{
+			hpv1.Service{
+				Name: path.Backend.ServiceName,
+				Port: int(path.Backend.ServicePort.IntVal),
+			},
+		},
+	}, nil
+}
+
+// ingressPath returns the path Contour matches for an Ingress path.
+func ingressPath(path netv1beta1.HTTPIngressPath) string {
+	if path.Path == "" {
+		return "/"
+	}
+	return path.Path
+}
+
+func ingressTimeoutPolicy(ing *netv1beta1.Ingress) (*hpv1.TimeoutPolicy, []warning.Warning) {
+
+	// The request-timeout annotation was always applied to the response.
+	annotation := "response-timeout"
+	timeout := compatAnnotation(ing, annotation)
+	if timeout == "" {
+		annotation = "request-timeout"
+		timeout = compatAnnotation(ing, annotation)
+	}
+	if timeout == "" {
+		return nil, nil
+	}
+
+	if timeout != "infinity" {
+		if _, err := time.ParseDuration(timeout); err != nil {
+			return nil, []warning.Warning{warning.New(warning.SeverityWarning, warning.IngressTimeoutInvalid, annotationPath(compatAnnotationKey(ing, annotation)),
+				"%s %s is not a valid duration, discarding. Please check the timeout is correct.", annotation, timeout)}
+		}
+	}
+
+	return &hpv1.TimeoutPolicy{
+		Response: timeout,
+	}, nil
+}
+
+func ingressRetryPolicy(ing *netv1beta1.Ingress) (*hpv1.RetryPolicy, []warning.Warning) {
+
+	var warnings []warning.Warning
+
+	numRetries := compatAnnotation(ing, "num-retries")
+	perTryTimeout := compatAnnotation(ing, "per-try-timeout")
+
+	// Contour only retries if retry-on is set.
+	retryOn := compatAnnotation(ing, "retry-on")
+	if retryOn == "" {
+		if numRetries != "" || perTryTimeout != "" {
+			warnings = append(warnings, warning.New(warning.SeverityInfo, warning.IngressRetryOnMissing, ".metadata.annotations",
+				"num-retries and per-try-timeout have no effect without retry-on, discarding. Please check the retry policy is correct."))
+		}
+		return nil, warnings
+	}
+
+	if retryOn != "5xx" {
+		warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IngressRetryOn, annotationPath(compatAnnotationKey(ing, "retry-on")),
+			"retry-on %s could not be applied, HTTPProxy always retries on 5xx. Please check the retry policy is correct.", retryOn))
+	}
+
+	retryPolicy := &hpv1.RetryPolicy{}
+	if numRetries != "" {
+		count, err := strconv.ParseUint(numRetries, 10, 32)
+		if err != nil {
+			warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IngressNumRetriesInvalid, annotationPath(compatAnnotationKey(ing, "num-retries")),
+				"num-retries %s is not a valid number, discarding. Please check the retry policy is correct.", numRetries))
+		} else {
+			retryPolicy.NumRetries = uint32(count)
+		}
+	}
+	if perTryTimeout != "" {
+		duration, err := time.ParseDuration(perTryTimeout)
+		if err != nil || duration < 0 {
+			warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IngressPerTryTimeoutInvalid, annotationPath(compatAnnotationKey(ing, "per-try-timeout")),
+				"per-try-timeout %s is not a valid duration, discarding. Please check the retry policy is correct.", perTryTimeout))
+		} else {
+			retryPolicy.PerTryTimeout = perTryTimeout
+		}
+	}
+
+	return retryPolicy, warnings
+}
+
+// ingressWebsocketRoutes returns the paths that have websockets enabled.
+func ingressWebsocketRoutes(ing *netv1beta1.Ingress) map[string]bool {
+	routes := make(map[string]bool)
+	for _, prefix := range []string{"projectcontour.io/", "contour.heptio.com/"} {
+		for _, route := range strings.Split(ing.Annotations[prefix+"websocket-routes"], ",") {
+			route = strings.TrimSpace(route)
+			if route != "" {
+				routes[route] = true
+			}
+		}
+	}
+	return routes
+}
+
+// compatAnnotation returns the value of a Contour annotation, with the
+// projectcontour.io/ prefix taking precedence, like Contour does.
+func compatAnnotation(ing *netv1beta1.Ingress, name string) string {
+	return ing.Annotations[compatAnnotationKey(ing, name)]
+}
+
+// compatAnnotationKey returns the key of the annotation that compatAnnotation
+// takes the value of a Contour annotation from.
+func compatAnnotationKey(ing *netv1beta1.Ingress, name string) string {
+	if _, ok := ing.Annotations["projectcontour.io/"+name]; ok {
+		return "projectcontour.io/" + name
+	}
+	return "contour.heptio.com/" + name
+}
+
+// annotationPath returns the path of an annotation, for a warning.
+func annotationPath(key string) string {
+	return fmt.Sprintf(".metadata.annotations['%s']", key)
+}
+
+// untranslatedAnnotations returns the annotations that aren't translated into
+// HTTPProxy fields, like the ingress class.
+func untranslatedAnnotations(annotations map[string]string) map[string]string {
+
+	translated := map[string]bool{
+		annotationForceSSLRedirect: true,
+		annotationAllowHTTP:        true,
+	}
+	for _, name := range compatAnnotations {
+		translated["projectcontour.io/"+name] = true
+		translated["contour.heptio.com/"+name] = true
+	}
+
+	var kept map[string]string
+	for key, value := range annotations {
+		if translated[key] {
+			continue
+		}
+		if kept == nil {
+			kept = make(map[string]string)
+		}
+		kept[key] = value
+	}
+	return kept
+}
@@ -0,0 +1,106 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+)
+
+func TestIngressToHTTPProxiesErrors(t *testing.T) {
+
+	tests := map[string]struct {
+		input []byte
+		want  string
+	}{
+		"only a default backend": {
+			input: []byte(`
+---
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: default-backend
+  namespace: default
+spec:
+  backend:
+    serviceName: s1
+    servicePort: 80
+`),
+			want: "can't translate Ingress default/default-backend: no rules have a host",
+		},
+		"regex path": {
+			input: []byte(`
+---
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: regex
+  namespace: default
+spec:
+  rules:
+  - host: foo.bar.com
+    http:
+      paths:
+      - path: /[a-z]+/api
+        backend:
+          serviceName: s1
+          servicePort: 80
+`),
+			want: "can't translate Ingress default/regex: path /[a-z]+/api is a regular expression, HTTPProxy only supports prefix conditions",
+		},
+		"named service port": {
+			input: []byte(`
+---
+apiVersion: networking.k8s.io/v1beta1
+kind: Ingress
+metadata:
+  name: named-port
+  namespace: default
+spec:
+  rules:
+  - host: foo.bar.com
+    http:
+      paths:
+      - path: /
+        backend:
+          serviceName: s1
+          servicePort: http
+`),
+			want: "can't translate Ingress default/named-port: servicePort http on service s1 is a port name, HTTPProxy services need a port number",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			items, err := k8sdecoder.Decode(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ing, ok := items[0].Object.(*netv1beta1.Ingress)
+			if !ok {
+				t.Fatalf("want an Ingress, got: %T", items[0].Object)
+			}
+			_, _, err = IngressToHTTPProxies(ing)
+			if err == nil {
+				t.Fatalf("want error %q, got none", tc.want)
+			}
+			if diff := cmp.Diff(err.Error(), tc.want); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
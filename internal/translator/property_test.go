@@ -0,0 +1,836 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/delegation"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	propertySeed       = flag.Int64("property.seed", 1, "seed for the IngressRoute trees generated by TestTranslationProperties")
+	propertyIterations = flag.Int("property.iterations", 500, "number of IngressRoute trees generated by TestTranslationProperties")
+	propertyFixture    = flag.String("property.fixture", "", "name of the testdata test case to write a failing TestTranslationProperties case to")
+)
+
+// TestTranslationProperties translates randomly generated IngressRoute trees,
+// one at a time and as a set, and checks the translations keep their meaning.
+// A failing tree is shrunk to the smallest tree that still fails. With
+// -property.fixture=name, the IngressRoute that fails is written out as a new
+// test case in testdata/name, like hack/newtestcase does.
+func TestTranslationProperties(t *testing.T) {
+
+	iterations := *propertyIterations
+	if testing.Short() {
+		iterations = 50
+	}
+
+	r := rand.New(rand.NewSource(*propertySeed))
+	for iteration := 0; iteration < iterations; iteration++ {
+		irs := generateIngressRouteTree(r)
+		violation := checkTranslationProperties(irs)
+		if violation == nil {
+			continue
+		}
+
+		irs, violation = shrinkIngressRouteTree(irs, violation, checkTranslationProperties)
+		treeYAML, err := marshalFixture(ingressRouteObjects(irs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *propertyFixture != "" {
+			if err := writePropertyFixture("testdata", *propertyFixture, violation.ir); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Fatalf("tree %d generated with -property.seed=%d: %s\nShrunk IngressRoutes:\n%s", iteration, *propertySeed, violation, treeYAML)
+	}
+}
+
+func TestShrinkIngressRouteTree(t *testing.T) {
+
+	// Pretend the translation of any route to service s2 on port 8080 is broken.
+	check := func(irs []*irv1beta1.IngressRoute) *propertyViolation {
+		for _, ir := range irs {
+			for _, route := range ir.Spec.Routes {
+				for _, service := range route.Services {
+					if service.Name == "s2" && service.Port == 8080 {
+						return &propertyViolation{ir: ir, message: "s2 is broken"}
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for iteration := 0; iteration < 100; iteration++ {
+		irs := generateIngressRouteTree(r)
+		violation := check(irs)
+		if violation == nil {
+			continue
+		}
+
+		shrunk, violation := shrinkIngressRouteTree(irs, violation, check)
+		if violation == nil {
+			t.Fatal("shrinking lost the violation")
+		}
+		if len(shrunk) != 1 || len(shrunk[0].Spec.Routes) != 1 || len(shrunk[0].Spec.Routes[0].Services) != 1 {
+			t.Fatalf("expected a single IngressRoute with a single route and service, got %+v", shrunk)
+		}
+		want := irv1beta1.Service{Name: "s2", Port: 8080}
+		if diff := cmp.Diff(shrunk[0].Spec.Routes[0].Services[0], want); diff != "" {
+			t.Fatalf("Service mismatch:\n%v", diff)
+		}
+		return
+	}
+	t.Fatal("no generated tree routes to s2 on port 8080")
+}
+
+func TestCheckTranslationCatchesDroppedPrefixes(t *testing.T) {
+
+	ir := &irv1beta1.IngressRoute{
+		ObjectMeta: v1.ObjectMeta{Name: "root", Namespace: "default"},
+		Spec: irv1beta1.IngressRouteSpec{
+			VirtualHost: &hpv1.VirtualHost{Fqdn: "foo.bar.com"},
+			Routes: []irv1beta1.Route{
+				{Match: "/", Services: []irv1beta1.Service{{Name: "s1", Port: 80}}},
+				{Match: "/api", Services: []irv1beta1.Service{{Name: "s2", Port: 80}}},
+				{Match: "/blog", Delegate: &irv1beta1.Delegate{Name: "blog", Namespace: "default"}},
+			},
+		},
+	}
+
+	// Each breakTranslation deliberately breaks the translation of ir, like a broken
+	// translator would, and returns the warnings it emits.
+	tests := map[string]struct {
+		breakTranslation func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning
+		wantViolation    bool
+	}{
+		"not broken": {
+			breakTranslation: func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning { return warnings },
+		},
+		"drops a route prefix": {
+			breakTranslation: func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning {
+				hp.Spec.Routes[1].Conditions = nil
+				return warnings
+			},
+			wantViolation: true,
+		},
+		"drops a route prefix, with a warning that mentions it": {
+			breakTranslation: func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning {
+				hp.Spec.Routes[1].Conditions = nil
+				return append(warnings, warning.New(warning.SeverityWarning, warning.LBConflict, ".spec.routes[1].services[0].strategy",
+					"Strategy Random on route /api could not be applied."))
+			},
+			wantViolation: true,
+		},
+		"drops a route prefix, with an include prefix warning for another route": {
+			breakTranslation: func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning {
+				hp.Spec.Routes[1].Conditions = nil
+				return append(warnings, includePrefixWarning("/", "/", ".spec.routes[0]"))
+			},
+			wantViolation: true,
+		},
+		"drops a route prefix, and warns about it": {
+			breakTranslation: func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning {
+				hp.Spec.Routes[1].Conditions = nil
+				return append(warnings, includePrefixWarning("/api", "/", ".spec.routes[1]"))
+			},
+		},
+		"drops an include prefix": {
+			breakTranslation: func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning {
+				hp.Spec.Includes[0].Conditions = nil
+				return warnings
+			},
+			wantViolation: true,
+		},
+		"drops an include prefix, and warns about it": {
+			breakTranslation: func(hp *hpv1.HTTPProxy, warnings []warning.Warning) []warning.Warning {
+				hp.Spec.Includes[0].Conditions = nil
+				return append(warnings, includePrefixWarning("/blog", "/", ".spec.routes[2]"))
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			hp, warnings, err := IngressRouteToHTTPProxy(ir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			warnings = tc.breakTranslation(hp, warnings)
+			message := checkTranslation(ir, "", hp, warnings, nil)
+			if got := message != ""; got != tc.wantViolation {
+				t.Fatalf("expected a violation: %v, got %q", tc.wantViolation, message)
+			}
+		})
+	}
+}
+
+func TestTranslationCanFail(t *testing.T) {
+
+	services := []irv1beta1.Service{{Name: "s1", Port: 80}}
+	tests := map[string]struct {
+		spec              irv1beta1.IngressRouteSpec
+		prefixKnown       bool
+		tcpproxyDelegated bool
+		want              bool
+	}{
+		"root": {
+			spec: irv1beta1.IngressRouteSpec{
+				VirtualHost: &hpv1.VirtualHost{Fqdn: "foo.bar.com"},
+				TCPProxy:    &irv1beta1.TCPProxy{Services: services},
+			},
+		},
+		"nonroot with a common prefix": {
+			spec: irv1beta1.IngressRouteSpec{Routes: []irv1beta1.Route{
+				{Match: "/api/v1", Services: services},
+				{Match: "/api/v2", Services: services},
+			}},
+		},
+		"nonroot with no common prefix": {
+			spec: irv1beta1.IngressRouteSpec{Routes: []irv1beta1.Route{
+				{Match: "/api", Services: services},
+				{Match: "/blog", Services: services},
+			}},
+			want: true,
+		},
+		"nonroot with no common prefix, delegated to": {
+			spec: irv1beta1.IngressRouteSpec{Routes: []irv1beta1.Route{
+				{Match: "/api", Services: services},
+				{Match: "/blog", Services: services},
+			}},
+			prefixKnown: true,
+		},
+		"nonroot tcpproxy": {
+			spec: irv1beta1.IngressRouteSpec{TCPProxy: &irv1beta1.TCPProxy{Services: services}},
+			want: true,
+		},
+		"nonroot tcpproxy, delegated to by a tcpproxy": {
+			spec:              irv1beta1.IngressRouteSpec{TCPProxy: &irv1beta1.TCPProxy{Services: services}},
+			tcpproxyDelegated: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ir := &irv1beta1.IngressRoute{Spec: tc.spec}
+			if got := translationCanFail(ir, tc.prefixKnown, tc.tcpproxyDelegated); got != tc.want {
+				t.Fatalf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWritePropertyFixture(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "property")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	irs := generateIngressRouteTree(rand.New(rand.NewSource(1)))
+	if err := writePropertyFixture(dir, "generated", irs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := ioutil.ReadFile(filepath.Join(dir, "generated", "input.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ioutil.ReadFile(filepath.Join(dir, "generated", "output.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "generated", "errors.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	translated, _, err := translateFixture(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputYAML, err := marshalFixture(translated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(outputYAML), string(output)); diff != "" {
+		t.Fatalf("output.yaml mismatch:\n%v", diff)
+	}
+}
+
+// propertyViolation is a translation that doesn't keep the meaning of the
+// IngressRoute it was translated from.
+type propertyViolation struct {
+	ir      *irv1beta1.IngressRoute
+	message string
+}
+
+func (v *propertyViolation) String() string {
+	return fmt.Sprintf("IngressRoute %s: %s", delegation.KeyOf(v.ir), v.message)
+}
+
+// checkTranslationProperties translates each IngressRoute in the tree on its
+// own, the way the fixtures are translated, and the tree as a set. It returns
+// the first translation that panics, that fails when it shouldn't, or that
+// doesn't keep the services, weights, includes and prefixes of the
+// IngressRoute, unless there's a warning about the prefix.
+func checkTranslationProperties(irs []*irv1beta1.IngressRoute) *propertyViolation {
+
+	graph := delegation.Build(irs)
+
+	for _, ir := range irs {
+		var hp *hpv1.HTTPProxy
+		var warnings []warning.Warning
+		var err error
+		if recovered := catchPanic(func() { hp, warnings, err = IngressRouteToHTTPProxy(ir) }); recovered != nil {
+			return &propertyViolation{ir: ir, message: fmt.Sprintf("translating on its own panicked: %v", recovered)}
+		}
+
+		// On its own, a nonroot IngressRoute isn't delegated to by anything.
+		if translationCanFail(ir, false, false) {
+			if err == nil {
+				return &propertyViolation{ir: ir, message: "translating on its own succeeded, but it can't be translated without its parent"}
+			}
+			continue
+		}
+		includePrefix := ""
+		if ir.Spec.VirtualHost == nil {
+			includePrefix, _, _ = guessIncludePrefix(ir.Spec.Routes)
+		}
+		if message := checkTranslation(ir, includePrefix, hp, warnings, err); message != "" {
+			return &propertyViolation{ir: ir, message: "translating on its own, " + message}
+		}
+	}
+
+	var translations []Translation
+	if recovered := catchPanic(func() { translations = IngressRoutesToHTTPProxies(irs) }); recovered != nil {
+		return &propertyViolation{ir: irs[0], message: fmt.Sprintf("translating the set panicked: %v", recovered)}
+	}
+
+	for _, translation := range translations {
+		ir := translation.IngressRoute
+		key := delegation.KeyOf(ir)
+		prefixes := graph.IncludePrefixes(key)
+		if translationCanFail(ir, len(prefixes) == 1, graph.IsTCPProxyDelegated(key)) {
+			if translation.Err == nil {
+				return &propertyViolation{ir: ir, message: "translating the set succeeded, but it's invalid without its parent"}
+			}
+			continue
+		}
+		includePrefix := ""
+		if ir.Spec.VirtualHost == nil {
+			switch len(prefixes) {
+			case 0:
+				includePrefix, _, _ = guessIncludePrefix(ir.Spec.Routes)
+			case 1:
+				includePrefix = prefixes[0]
+			default:
+				// The include prefix isn't known, so there's no way to tell
+				// if the prefixes were kept.
+				continue
+			}
+		}
+		if message := checkTranslation(ir, includePrefix, translation.HTTPProxy, translation.Warnings, translation.Err); message != "" {
+			return &propertyViolation{ir: ir, message: "translating the set, " + message}
+		}
+	}
+
+	return nil
+}
+
+// checkTranslation checks a single translation, and describes what's wrong with it.
+// It returns the empty string if there's nothing wrong.
+func checkTranslation(ir *irv1beta1.IngressRoute, includePrefix string, hp *hpv1.HTTPProxy, warnings []warning.Warning, err error) string {
+
+	if err != nil {
+		return fmt.Sprintf("got an unexpected error: %s", err)
+	}
+
+	// A '/' include prefix isn't trimmed from the matches.
+	if includePrefix == "/" {
+		includePrefix = ""
+	}
+
+	// A prefix is kept if the conditions give the same match under the
+	// include prefix, or if there's an include prefix warning for the match
+	// of the route at index.
+	kept := func(index int, match string, conditions []hpv1.Condition) bool {
+		prefix, err := conditionPrefix(conditions)
+		if err == nil && joinPrefixes(includePrefix, prefix) == match {
+			return true
+		}
+		path := fmt.Sprintf(".spec.routes[%d].match", index)
+		for _, w := range warnings {
+			if w.Path == path && includePrefixCodes[w.Code] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var delegates []string
+	var routes []irv1beta1.Route
+	// routeIndexes are the indexes of routes in the IngressRoute.
+	var routeIndexes []int
+	for index, route := range ir.Spec.Routes {
+		if route.Delegate != nil {
+			delegates = append(delegates, delegateName(route.Delegate))
+			continue
+		}
+		routes = append(routes, route)
+		routeIndexes = append(routeIndexes, index)
+	}
+
+	if len(hp.Spec.Routes) != len(routes) {
+		return fmt.Sprintf("has %d routes, but the HTTPProxy has %d", len(routes), len(hp.Spec.Routes))
+	}
+	for index, route := range routes {
+		hpRoute := hp.Spec.Routes[index]
+		if !kept(routeIndexes[index], route.Match, hpRoute.Conditions) {
+			return fmt.Sprintf("route %s changed prefix without a warning", route.Match)
+		}
+		if diff := cmp.Diff(ingressRouteServices(route.Services), httpProxyServices(hpRoute.Services)); diff != "" {
+			return fmt.Sprintf("route %s changed services or weights:\n%s", route.Match, diff)
+		}
+		for _, service := range route.Services {
+			if service.Strategy != "" && hpRoute.LoadBalancerPolicy == nil {
+				return fmt.Sprintf("route %s lost its load balancing strategy", route.Match)
+			}
+			if service.HealthCheck != nil && hpRoute.HealthCheckPolicy == nil {
+				return fmt.Sprintf("route %s lost its healthcheck", route.Match)
+			}
+		}
+	}
+
+	if tcpproxy := ir.Spec.TCPProxy; tcpproxy != nil {
+		if tcpproxy.Delegate != nil {
+			var include string
+			if hp.Spec.TCPProxy != nil && hp.Spec.TCPProxy.Include != nil {
+				include = hp.Spec.TCPProxy.Include.Namespace + "/" + hp.Spec.TCPProxy.Include.Name
+			}
+			if include != delegateName(tcpproxy.Delegate) {
+				return fmt.Sprintf("tcpproxy delegate %s became tcpproxy include %q", delegateName(tcpproxy.Delegate), include)
+			}
+		} else {
+			var hpServices []hpv1.Service
+			if hp.Spec.TCPProxy != nil {
+				hpServices = hp.Spec.TCPProxy.Services
+			}
+			if diff := cmp.Diff(ingressRouteServices(tcpproxy.Services), httpProxyServices(hpServices)); diff != "" {
+				return fmt.Sprintf("tcpproxy changed services or weights:\n%s", diff)
+			}
+		}
+	}
+
+	var includes []string
+	for _, include := range hp.Spec.Includes {
+		includes = append(includes, include.Namespace+"/"+include.Name)
+	}
+	sort.Strings(delegates)
+	sort.Strings(includes)
+	if diff := cmp.Diff(delegates, includes); diff != "" {
+		return fmt.Sprintf("changed delegates:\n%s", diff)
+	}
+	for index, route := range ir.Spec.Routes {
+		if route.Delegate == nil {
+			continue
+		}
+		for _, include := range hp.Spec.Includes {
+			if include.Namespace == route.Delegate.Namespace && include.Name == route.Delegate.Name && !kept(index, route.Match, include.Conditions) {
+				return fmt.Sprintf("delegate at %s changed prefix without a warning", route.Match)
+			}
+		}
+	}
+
+	return ""
+}
+
+// translationCanFail returns true if a nonroot IngressRoute in a tree can't
+// be translated without more of the tree: it has a tcpproxy, but isn't known to
+// be delegated to by a tcpproxy, or its include prefix isn't known and can't be
+// guessed, since its matches have no common prefix.
+// Any other IngressRoute in a generated tree must translate without an error.
+func translationCanFail(ir *irv1beta1.IngressRoute, prefixKnown bool, tcpproxyDelegated bool) bool {
+	if ir.Spec.VirtualHost != nil {
+		return false
+	}
+	if ir.Spec.TCPProxy != nil && !tcpproxyDelegated {
+		return true
+	}
+	if prefixKnown {
+		return false
+	}
+	_, _, err := guessIncludePrefix(ir.Spec.Routes)
+	return err != nil
+}
+
+// includePrefixCodes are the codes of the warnings that say a match couldn't
+// be kept exactly under the include prefix.
+var includePrefixCodes = map[warning.Code]bool{
+	warning.IncludePrefixSingleMatch: true,
+	warning.IncludePrefixGuess:       true,
+	warning.IncludePrefixInexact:     true,
+	warning.IncludePrefixMultiple:    true,
+}
+
+func delegateName(delegate *irv1beta1.Delegate) string {
+	return delegate.Namespace + "/" + delegate.Name
+}
+
+func ingressRouteServices(services []irv1beta1.Service) []string {
+	var names []string
+	for _, service := range services {
+		names = append(names, fmt.Sprintf("%s:%d weight %d", service.Name, service.Port, service.Weight))
+	}
+	return names
+}
+
+func httpProxyServices(services []hpv1.Service) []string {
+	var names []string
+	for _, service := range services {
+		names = append(names, fmt.Sprintf("%s:%d weight %d", service.Name, service.Port, service.Weight))
+	}
+	return names
+}
+
+// catchPanic runs f, and returns what it panicked with, if anything.
+func catchPanic(f func()) (recovered interface{}) {
+	defer func() {
+		recovered = recover()
+	}()
+	f()
+	return nil
+}
+
+// shrinkIngressRouteTree removes objects, routes, services and fields from a
+// tree that fails check, for as long as it still fails. It returns the
+// smallest failing tree it finds, and why that fails.
+func shrinkIngressRouteTree(irs []*irv1beta1.IngressRoute, violation *propertyViolation, check func([]*irv1beta1.IngressRoute) *propertyViolation) ([]*irv1beta1.IngressRoute, *propertyViolation) {
+
+	for {
+		shrunk := false
+		for _, candidate := range shrinkCandidates(irs) {
+			if candidateViolation := check(candidate); candidateViolation != nil {
+				irs, violation = candidate, candidateViolation
+				shrunk = true
+				break
+			}
+		}
+		if !shrunk {
+			return irs, violation
+		}
+	}
+}
+
+// shrinkCandidates returns copies of a tree that are each a little smaller.
+func shrinkCandidates(irs []*irv1beta1.IngressRoute) [][]*irv1beta1.IngressRoute {
+
+	var candidates [][]*irv1beta1.IngressRoute
+
+	// shrink adds a copy of the tree with the IngressRoute at index changed by f.
+	shrink := func(index int, f func(ir *irv1beta1.IngressRoute)) {
+		candidate := make([]*irv1beta1.IngressRoute, len(irs))
+		for i, ir := range irs {
+			candidate[i] = ir.DeepCopy()
+		}
+		f(candidate[index])
+		candidates = append(candidates, candidate)
+	}
+
+	if len(irs) > 1 {
+		for index := range irs {
+			candidate := make([]*irv1beta1.IngressRoute, 0, len(irs)-1)
+			candidate = append(candidate, irs[:index]...)
+			candidates = append(candidates, append(candidate, irs[index+1:]...))
+		}
+	}
+
+	for index, ir := range irs {
+		if ir.Spec.TCPProxy != nil {
+			shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.TCPProxy = nil })
+			for s := range ir.Spec.TCPProxy.Services {
+				s := s
+				shrink(index, func(ir *irv1beta1.IngressRoute) {
+					ir.Spec.TCPProxy.Services = append(ir.Spec.TCPProxy.Services[:s], ir.Spec.TCPProxy.Services[s+1:]...)
+				})
+			}
+		}
+		if ir.Spec.VirtualHost != nil && ir.Spec.VirtualHost.TLS != nil {
+			shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.VirtualHost.TLS = nil })
+		}
+
+		for r, route := range ir.Spec.Routes {
+			r := r
+			shrink(index, func(ir *irv1beta1.IngressRoute) {
+				ir.Spec.Routes = append(ir.Spec.Routes[:r], ir.Spec.Routes[r+1:]...)
+			})
+			if route.EnableWebsockets {
+				shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].EnableWebsockets = false })
+			}
+			if route.PermitInsecure {
+				shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].PermitInsecure = false })
+			}
+			if route.TimeoutPolicy != nil {
+				shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].TimeoutPolicy = nil })
+			}
+			if route.RetryPolicy != nil {
+				shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].RetryPolicy = nil })
+			}
+			if route.PrefixRewrite != "" {
+				shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].PrefixRewrite = "" })
+			}
+
+			for s, service := range route.Services {
+				s := s
+				shrink(index, func(ir *irv1beta1.IngressRoute) {
+					ir.Spec.Routes[r].Services = append(ir.Spec.Routes[r].Services[:s], ir.Spec.Routes[r].Services[s+1:]...)
+				})
+				if service.Weight != 0 {
+					shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].Services[s].Weight = 0 })
+				}
+				if service.Strategy != "" {
+					shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].Services[s].Strategy = "" })
+				}
+				if service.HealthCheck != nil {
+					shrink(index, func(ir *irv1beta1.IngressRoute) { ir.Spec.Routes[r].Services[s].HealthCheck = nil })
+				}
+			}
+		}
+	}
+
+	return candidates
+}
+
+// writePropertyFixture writes an IngressRoute as a new test case in dir, with
+// the files that hack/newtestcase creates. output.yaml and errors.txt hold the
+// current translation, which needs fixing by hand if it's wrong.
+func writePropertyFixture(dir string, name string, ir *irv1beta1.IngressRoute) error {
+
+	input, err := marshalFixture(ingressRouteObjects([]*irv1beta1.IngressRoute{ir}))
+	if err != nil {
+		return err
+	}
+
+	var output []byte
+	var errors string
+	hp, warnings, err := IngressRouteToHTTPProxy(ir)
+	if err == nil {
+		output, err = marshalFixture([]interface{}{hp})
+		if err != nil {
+			return err
+		}
+		errors = strings.Join(warning.Strings(warnings), "\n")
+	}
+
+	caseDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(caseDir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(caseDir, "input.yaml"), input, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(caseDir, "output.yaml"), output, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(caseDir, "errors.txt"), []byte(errors), 0644)
+}
+
+func ingressRouteObjects(irs []*irv1beta1.IngressRoute) []interface{} {
+	var objs []interface{}
+	for _, ir := range irs {
+		objs = append(objs, ir)
+	}
+	return objs
+}
+
+var (
+	generatedSegments   = []string{"api", "blog", "static", "v1", "v2"}
+	generatedServices   = []string{"s1", "s2", "s3"}
+	generatedStrategies = []string{"Cookie", "Random", "RoundRobin", "WeightedLeastRequest"}
+)
+
+// treeGenerator generates a random, valid IngressRoute tree.
+type treeGenerator struct {
+	rand *rand.Rand
+	irs  []*irv1beta1.IngressRoute
+}
+
+// generateIngressRouteTree returns a root IngressRoute followed by the
+// IngressRoutes it delegates to, at most two levels deep. Each delegated
+// IngressRoute only matches paths under the match it's delegated at.
+func generateIngressRouteTree(r *rand.Rand) []*irv1beta1.IngressRoute {
+
+	g := &treeGenerator{rand: r}
+	root := g.ingressRoute("root", "default")
+	root.Spec.VirtualHost = &hpv1.VirtualHost{Fqdn: "foo.bar.com"}
+
+	switch g.rand.Intn(6) {
+	case 0, 1:
+		root.Spec.VirtualHost.TLS = &hpv1.TLS{SecretName: "secret"}
+	case 2:
+		root.Spec.VirtualHost.TLS = &hpv1.TLS{Passthrough: true}
+	}
+	if tls := root.Spec.VirtualHost.TLS; tls != nil && (tls.Passthrough || g.chance(2)) {
+		root.Spec.TCPProxy = g.tcpProxy()
+	}
+
+	root.Spec.Routes = g.routes(root.Namespace, "", 0)
+	return g.irs
+}
+
+// ingressRoute adds an empty IngressRoute to the tree.
+func (g *treeGenerator) ingressRoute(name string, namespace string) *irv1beta1.IngressRoute {
+	ir := &irv1beta1.IngressRoute{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "IngressRoute",
+			APIVersion: "contour.heptio.com/v1beta1",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+	g.irs = append(g.irs, ir)
+	return ir
+}
+
+// chance returns true one time in n.
+func (g *treeGenerator) chance(n int) bool {
+	return g.rand.Intn(n) == 0
+}
+
+func (g *treeGenerator) segment() string {
+	return generatedSegments[g.rand.Intn(len(generatedSegments))]
+}
+
+// match returns a match under the match that the routes are delegated at,
+// which is empty for a root IngressRoute.
+func (g *treeGenerator) match(delegatedAt string) string {
+
+	if delegatedAt == "" {
+		switch g.rand.Intn(4) {
+		case 0:
+			return "/"
+		case 1:
+			return "/" + g.segment()
+		case 2:
+			return "/" + g.segment() + "/"
+		default:
+			return "/" + g.segment() + "/" + g.segment()
+		}
+	}
+
+	switch g.rand.Intn(3) {
+	case 0:
+		return delegatedAt
+	case 1:
+		return strings.TrimSuffix(delegatedAt, "/") + "/" + g.segment()
+	default:
+		// Not a path segment under the delegated match, like /apiv1 under /api.
+		return delegatedAt + g.segment()
+	}
+}
+
+func (g *treeGenerator) routes(namespace string, delegatedAt string, depth int) []irv1beta1.Route {
+
+	var routes []irv1beta1.Route
+	for count := 1 + g.rand.Intn(3); count > 0; count-- {
+		route := irv1beta1.Route{
+			Match: g.match(delegatedAt),
+		}
+
+		if depth < 2 && g.chance(4) {
+			childNamespace := namespace
+			if g.chance(3) {
+				childNamespace = "blog"
+			}
+			child := g.ingressRoute(fmt.Sprintf("child-%d", len(g.irs)), childNamespace)
+			route.Delegate = &irv1beta1.Delegate{Name: child.Name, Namespace: childNamespace}
+			child.Spec.Routes = g.routes(childNamespace, route.Match, depth+1)
+			routes = append(routes, route)
+			continue
+		}
+
+		route.EnableWebsockets = g.chance(5)
+		route.PermitInsecure = g.chance(5)
+		if g.chance(5) {
+			route.TimeoutPolicy = &irv1beta1.TimeoutPolicy{Request: []string{"1s", "infinity"}[g.rand.Intn(2)]}
+		}
+		if g.chance(5) {
+			route.RetryPolicy = &hpv1.RetryPolicy{NumRetries: uint32(1 + g.rand.Intn(3)), PerTryTimeout: "150ms"}
+		}
+		if g.chance(6) {
+			route.PrefixRewrite = "/"
+		}
+		route.Services = g.services()
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// tcpProxy returns a tcpproxy with services, or one that delegates to a
+// nonroot IngressRoute with the services.
+func (g *treeGenerator) tcpProxy() *irv1beta1.TCPProxy {
+	if g.chance(4) {
+		child := g.ingressRoute("tcp", "default")
+		child.Spec.TCPProxy = &irv1beta1.TCPProxy{Services: g.services()}
+		return &irv1beta1.TCPProxy{Delegate: &irv1beta1.Delegate{Name: child.Name, Namespace: child.Namespace}}
+	}
+	return &irv1beta1.TCPProxy{Services: g.services()}
+}
+
+func (g *treeGenerator) services() []irv1beta1.Service {
+
+	var services []irv1beta1.Service
+	for count := 1 + g.rand.Intn(3); count > 0; count-- {
+		service := irv1beta1.Service{
+			Name: generatedServices[g.rand.Intn(len(generatedServices))],
+			Port: []int{80, 8080}[g.rand.Intn(2)],
+		}
+		if g.chance(2) {
+			service.Weight = uint32(g.rand.Intn(101))
+		}
+		if g.chance(3) {
+			service.Strategy = generatedStrategies[g.rand.Intn(len(generatedStrategies))]
+		}
+		if g.chance(4) {
+			service.HealthCheck = &irv1beta1.HealthCheck{
+				Path:                    "/healthz",
+				IntervalSeconds:         int64(1 + g.rand.Intn(10)),
+				TimeoutSeconds:          int64(1 + g.rand.Intn(5)),
+				UnhealthyThresholdCount: uint32(1 + g.rand.Intn(5)),
+				HealthyThresholdCount:   uint32(1 + g.rand.Intn(5)),
+			}
+		}
+		services = append(services, service)
+	}
+	return services
+}
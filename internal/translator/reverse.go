@@ -0,0 +1,418 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/delegation"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReverseTranslation is the result of translating one HTTPProxy in a set back
+// to an IngressRoute.
+type ReverseTranslation struct {
+	HTTPProxy    *hpv1.HTTPProxy
+	IngressRoute *irv1beta1.IngressRoute
+	Warnings     []warning.Warning
+	Err          error
+}
+
+// HTTPProxyToIngressRoute translates a HTTPProxy object back to an IngressRoute one,
+// emitting warnings as it goes. It returns an error for HTTPProxy features that
+// IngressRoute doesn't have, like header conditions.
+// The include prefix of a nonroot HTTPProxy isn't known, so it can't be added back to
+// the matches of the IngressRoute. Use HTTPProxiesToIngressRoutes to translate a nonroot
+// HTTPProxy along with the HTTPProxy that includes it.
+func HTTPProxyToIngressRoute(hp *hpv1.HTTPProxy) (*irv1beta1.IngressRoute, []warning.Warning, error) {
+	var warnings []warning.Warning
+	if hp.Spec.VirtualHost == nil {
+		warnings = append(warnings, nonrootPrefixUnknownWarning(hp))
+	}
+	ir, err := translateHTTPProxy(hp, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return ir, warnings, nil
+}
+
+// nonrootPrefixUnknownWarning warns that the include prefix of a nonroot
+// HTTPProxy isn't known.
+func nonrootPrefixUnknownWarning(hp *hpv1.HTTPProxy) warning.Warning {
+	w := warning.New(warning.SeverityWarning, warning.ReverseIncludePrefixGuess, "",
+		"No HTTPProxy in the input includes this one, so its include path is unknown, and wasn't added to the IngressRoute matches. IngressRoute matches must include the delegation prefix. Please check these values are correct.")
+	w.Object = httpProxyObject(hp)
+	return w
+}
+
+// httpProxyObject returns the warning.Object for an HTTPProxy.
+func httpProxyObject(hp *hpv1.HTTPProxy) warning.Object {
+	return warning.Object{Kind: "HTTPProxy", Namespace: hp.Namespace, Name: hp.Name}
+}
+
+// HTTPProxiesToIngressRoutes translates a set of HTTPProxy objects back to IngressRoute ones,
+// returning a ReverseTranslation for each, in the same order.
+// The include prefix of a nonroot HTTPProxy is taken from the includes that lead to it,
+// and prepended to its matches, since IngressRoute matches have the full path.
+func HTTPProxiesToIngressRoutes(hps []*hpv1.HTTPProxy) []ReverseTranslation {
+
+	nodes := make(map[delegation.Key]*hpv1.HTTPProxy)
+	parents := make(map[delegation.Key][]includeEdge)
+	for _, hp := range hps {
+		key := httpProxyKey(hp)
+		nodes[key] = hp
+		for _, include := range hp.Spec.Includes {
+			child := delegation.Key{Namespace: include.Namespace, Name: include.Name}
+			if child.Namespace == "" {
+				child.Namespace = hp.Namespace
+			}
+			parents[child] = append(parents[child], includeEdge{parent: key, include: include})
+		}
+	}
+
+	paths := &includePaths{
+		nodes:   nodes,
+		parents: parents,
+		paths:   make(map[delegation.Key][]string),
+		visited: make(map[delegation.Key]bool),
+	}
+
+	translations := make([]ReverseTranslation, 0, len(hps))
+	for _, hp := range hps {
+		var warnings []warning.Warning
+		var includePrefix string
+		if hp.Spec.VirtualHost == nil {
+			prefixes, err := paths.of(httpProxyKey(hp))
+			if err != nil {
+				translations = append(translations, ReverseTranslation{HTTPProxy: hp, Err: err})
+				continue
+			}
+			switch len(prefixes) {
+			case 0:
+				warnings = append(warnings, nonrootPrefixUnknownWarning(hp))
+			case 1:
+				includePrefix = prefixes[0]
+			default:
+				translations = append(translations, ReverseTranslation{
+					HTTPProxy: hp,
+					Err:       fmt.Errorf("can't translate HTTPProxy %s/%s: it is included at more than one path (%s), and IngressRoute matches can only include one delegation prefix", hp.Namespace, hp.Name, strings.Join(prefixes, ", ")),
+				})
+				continue
+			}
+		}
+
+		ir, err := translateHTTPProxy(hp, includePrefix)
+		translations = append(translations, ReverseTranslation{
+			HTTPProxy:    hp,
+			IngressRoute: ir,
+			Warnings:     warnings,
+			Err:          err,
+		})
+	}
+
+	return translations
+}
+
+func httpProxyKey(hp *hpv1.HTTPProxy) delegation.Key {
+	return delegation.Key{Namespace: hp.Namespace, Name: hp.Name}
+}
+
+// includeEdge is an include from a parent HTTPProxy.
+type includeEdge struct {
+	parent  delegation.Key
+	include hpv1.Include
+}
+
+// includePaths finds the full paths that nonroot HTTPProxies are included at.
+type includePaths struct {
+	nodes   map[delegation.Key]*hpv1.HTTPProxy
+	parents map[delegation.Key][]includeEdge
+	paths   map[delegation.Key][]string
+	visited map[delegation.Key]bool
+}
+
+// of returns the distinct full paths the HTTPProxy for key is included at.
+// It returns no paths for a root HTTPProxy, or one that isn't included by
+// any HTTPProxy in the set.
+func (p *includePaths) of(key delegation.Key) ([]string, error) {
+
+	if paths, ok := p.paths[key]; ok {
+		return paths, nil
+	}
+	if p.visited[key] {
+		return nil, fmt.Errorf("can't translate HTTPProxy %s: it is part of an include cycle", key)
+	}
+	p.visited[key] = true
+
+	hp, ok := p.nodes[key]
+	if !ok || hp.Spec.VirtualHost != nil {
+		p.paths[key] = nil
+		return nil, nil
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+	for _, edge := range p.parents[key] {
+		prefix, err := includeConditionPrefix(edge.include)
+		if err != nil {
+			return nil, fmt.Errorf("can't translate HTTPProxy %s: %s", key, err)
+		}
+		parentPaths, err := p.of(edge.parent)
+		if err != nil {
+			return nil, err
+		}
+		if len(parentPaths) == 0 {
+			// The parent is a root, or its own path is unknown.
+			parentPaths = []string{""}
+		}
+		for _, parentPath := range parentPaths {
+			path := joinPrefixes(parentPath, prefix)
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	p.paths[key] = paths
+	return paths, nil
+}
+
+var multipleSlashes = regexp.MustCompile(`//+`)
+
+// joinPrefixes joins an include prefix and a condition prefix the way Contour
+// does, by appending them and collapsing any run of '/'.
+func joinPrefixes(includePrefix string, prefix string) string {
+	joined := multipleSlashes.ReplaceAllString(includePrefix+prefix, "/")
+	if joined == "" {
+		return "/"
+	}
+	return joined
+}
+
+// conditionPrefix returns the prefix of a set of conditions, which IngressRoute
+// can only represent if there's at most one prefix condition.
+func conditionPrefix(conditions []hpv1.Condition) (string, error) {
+	if len(conditions) > 1 {
+		return "", fmt.Errorf("has more than one condition, which IngressRoute does not support")
+	}
+	for _, condition := range conditions {
+		if condition.Header != nil {
+			return "", fmt.Errorf("has a header condition on %s, which IngressRoute does not support", condition.Header.Name)
+		}
+		return condition.Prefix, nil
+	}
+	return "", nil
+}
+
+func includeConditionPrefix(include hpv1.Include) (string, error) {
+	prefix, err := conditionPrefix(include.Conditions)
+	if err != nil {
+		return "", fmt.Errorf("the include of %s %s", include.Name, err)
+	}
+	return prefix, nil
+}
+
+// translateHTTPProxy translates a single HTTPProxy. includePrefix is the full path
+// a nonroot HTTPProxy is included at, which is added to the IngressRoute's matches.
+func translateHTTPProxy(hp *hpv1.HTTPProxy, includePrefix string) (*irv1beta1.IngressRoute, error) {
+
+	unsupported := func(err error) error {
+		return fmt.Errorf("can't translate HTTPProxy %s/%s: %s", hp.Namespace, hp.Name, err)
+	}
+
+	var routes []irv1beta1.Route
+	for _, hpRoute := range hp.Spec.Routes {
+		route, err := translateHTTPProxyRoute(hpRoute, includePrefix)
+		if err != nil {
+			return nil, unsupported(err)
+		}
+		routes = append(routes, route)
+	}
+
+	for _, include := range hp.Spec.Includes {
+		prefix, err := includeConditionPrefix(include)
+		if err != nil {
+			return nil, unsupported(err)
+		}
+		routes = append(routes, irv1beta1.Route{
+			Match: joinPrefixes(includePrefix, prefix),
+			Delegate: &irv1beta1.Delegate{
+				Name:      include.Name,
+				Namespace: include.Namespace,
+			},
+		})
+	}
+
+	var tcpproxy *irv1beta1.TCPProxy
+	if hp.Spec.TCPProxy != nil {
+		var err error
+		tcpproxy, err = translateHTTPProxyTCPProxy(hp.Spec.TCPProxy)
+		if err != nil {
+			return nil, unsupported(err)
+		}
+	}
+
+	ir := &irv1beta1.IngressRoute{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "IngressRoute",
+			APIVersion: "contour.heptio.com/v1beta1",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:        hp.ObjectMeta.Name,
+			Namespace:   hp.ObjectMeta.Namespace,
+			Labels:      hp.ObjectMeta.DeepCopy().GetLabels(),
+			Annotations: hp.ObjectMeta.DeepCopy().GetAnnotations(),
+		},
+		Spec: irv1beta1.IngressRouteSpec{
+			VirtualHost: hp.Spec.VirtualHost,
+			Routes:      routes,
+			TCPProxy:    tcpproxy,
+		},
+	}
+
+	return ir, nil
+}
+
+func translateHTTPProxyRoute(hpRoute hpv1.Route, includePrefix string) (irv1beta1.Route, error) {
+
+	prefix, err := conditionPrefix(hpRoute.Conditions)
+	if err != nil {
+		return irv1beta1.Route{}, fmt.Errorf("a route %s", err)
+	}
+	match := joinPrefixes(includePrefix, prefix)
+
+	unsupported := func(feature string) error {
+		return fmt.Errorf("route %s has %s, which IngressRoute does not support", match, feature)
+	}
+
+	if hpRoute.RequestHeadersPolicy != nil || hpRoute.ResponseHeadersPolicy != nil {
+		return irv1beta1.Route{}, unsupported("a headers policy")
+	}
+
+	route := irv1beta1.Route{
+		Match:            match,
+		EnableWebsockets: hpRoute.EnableWebsockets,
+		PermitInsecure:   hpRoute.PermitInsecure,
+	}
+
+	if hpRoute.TimeoutPolicy != nil {
+		if hpRoute.TimeoutPolicy.Idle != "" {
+			return irv1beta1.Route{}, unsupported("an idle timeout")
+		}
+		route.TimeoutPolicy = &irv1beta1.TimeoutPolicy{
+			Request: hpRoute.TimeoutPolicy.Response,
+		}
+	}
+
+	if hpRoute.RetryPolicy != nil {
+		route.RetryPolicy = hpRoute.RetryPolicy.DeepCopy()
+	}
+
+	if hpRoute.PathRewritePolicy != nil {
+		replacePrefix := hpRoute.PathRewritePolicy.ReplacePrefix
+		if len(replacePrefix) > 1 || (len(replacePrefix) == 1 && replacePrefix[0].Prefix != "") {
+			return irv1beta1.Route{}, unsupported("a path rewrite for a specific prefix")
+		}
+		if len(replacePrefix) == 1 {
+			route.PrefixRewrite = replacePrefix[0].Replacement
+		}
+	}
+
+	// HTTPProxy's route level policies are per service in IngressRoute.
+	var strategy string
+	if hpRoute.LoadBalancerPolicy != nil {
+		strategy = hpRoute.LoadBalancerPolicy.Strategy
+	}
+	for _, hpService := range hpRoute.Services {
+		service, err := translateHTTPProxyService(hpService, strategy)
+		if err != nil {
+			return irv1beta1.Route{}, fmt.Errorf("route %s: %s", match, err)
+		}
+		if policy := hpRoute.HealthCheckPolicy; policy != nil {
+			service.HealthCheck = &irv1beta1.HealthCheck{
+				Path:                    policy.Path,
+				Host:                    policy.Host,
+				IntervalSeconds:         policy.IntervalSeconds,
+				TimeoutSeconds:          policy.TimeoutSeconds,
+				UnhealthyThresholdCount: policy.UnhealthyThresholdCount,
+				HealthyThresholdCount:   policy.HealthyThresholdCount,
+			}
+		}
+		route.Services = append(route.Services, service)
+	}
+
+	return route, nil
+}
+
+func translateHTTPProxyTCPProxy(hpTCPProxy *hpv1.TCPProxy) (*irv1beta1.TCPProxy, error) {
+
+	tcpproxy := &irv1beta1.TCPProxy{}
+
+	if hpTCPProxy.Include != nil {
+		if len(hpTCPProxy.Services) > 0 {
+			return nil, fmt.Errorf("tcpproxy has both an include and services, which IngressRoute does not support")
+		}
+		tcpproxy.Delegate = &irv1beta1.Delegate{
+			Name:      hpTCPProxy.Include.Name,
+			Namespace: hpTCPProxy.Include.Namespace,
+		}
+		return tcpproxy, nil
+	}
+
+	var strategy string
+	if hpTCPProxy.LoadBalancerPolicy != nil {
+		strategy = hpTCPProxy.LoadBalancerPolicy.Strategy
+	}
+	for _, hpService := range hpTCPProxy.Services {
+		service, err := translateHTTPProxyService(hpService, strategy)
+		if err != nil {
+			return nil, fmt.Errorf("tcpproxy: %s", err)
+		}
+		tcpproxy.Services = append(tcpproxy.Services, service)
+	}
+	return tcpproxy, nil
+}
+
+func translateHTTPProxyService(hpService hpv1.Service, strategy string) (irv1beta1.Service, error) {
+
+	unsupported := func(feature string) error {
+		return fmt.Errorf("service %s has %s, which IngressRoute does not support", hpService.Name, feature)
+	}
+
+	switch {
+	case hpService.Protocol != nil:
+		return irv1beta1.Service{}, unsupported("a protocol")
+	case hpService.Mirror:
+		return irv1beta1.Service{}, unsupported("mirroring")
+	case hpService.RequestHeadersPolicy != nil || hpService.ResponseHeadersPolicy != nil:
+		return irv1beta1.Service{}, unsupported("a headers policy")
+	}
+
+	service := irv1beta1.Service{
+		Name:     hpService.Name,
+		Port:     hpService.Port,
+		Weight:   hpService.Weight,
+		Strategy: strategy,
+	}
+	if hpService.UpstreamValidation != nil {
+		service.UpstreamValidation = hpService.UpstreamValidation.DeepCopy()
+	}
+	return service, nil
+}
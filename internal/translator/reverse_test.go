@@ -0,0 +1,513 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+)
+
+func TestHTTPProxiesToIngressRoutes(t *testing.T) {
+
+	tests := map[string]struct {
+		inputs   []string
+		warnings [][]string
+	}{
+		"nested delegation": {
+			inputs:   []string{setRoot, setService2, setAPI},
+			warnings: [][]string{nil, nil, nil},
+		},
+		"parent not in the set": {
+			inputs:   []string{setService2},
+			warnings: [][]string{{nonrootPrefixUnknownWarning(&hpv1.HTTPProxy{}).String()}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var irs []*irv1beta1.IngressRoute
+			for _, input := range tc.inputs {
+				ir, err := k8sdecoder.DecodeIngressRoute([]byte(input))
+				if err != nil {
+					t.Fatal(err)
+				}
+				irs = append(irs, ir)
+			}
+
+			var hps []*hpv1.HTTPProxy
+			for _, translation := range IngressRoutesToHTTPProxies(irs) {
+				if translation.Err != nil {
+					t.Fatal(translation.Err)
+				}
+				hps = append(hps, translation.HTTPProxy)
+			}
+
+			var warnings [][]string
+			for i, translation := range HTTPProxiesToIngressRoutes(hps) {
+				if translation.Err != nil {
+					t.Fatal(translation.Err)
+				}
+				warnings = append(warnings, warning.Strings(translation.Warnings))
+
+				// When the whole delegation tree is in the set, the include
+				// prefixes are known, and the IngressRoutes come back unchanged.
+				if tc.warnings[i] != nil {
+					continue
+				}
+				if diff := cmp.Diff(translation.IngressRoute, irs[i]); diff != "" {
+					t.Fatalf("Round trip of %s/%s failed:\n%v", irs[i].Namespace, irs[i].Name, diff)
+				}
+			}
+			if diff := cmp.Diff(warnings, tc.warnings); diff != "" {
+				t.Fatalf("Translation Warnings Mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPProxiesToIngressRoutesPrefixes(t *testing.T) {
+
+	hps := decodeHTTPProxies(t, `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: root.bar.com
+  includes:
+  - name: blog
+    conditions:
+    - prefix: /blog/
+  - name: shop
+    namespace: shop
+    conditions:
+    - prefix: /shop
+`, `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: blog
+  namespace: default
+spec:
+  routes:
+  - services:
+    - name: blog
+      port: 80
+  - conditions:
+    - prefix: /admin
+    services:
+    - name: admin
+      port: 80
+`, `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  includes:
+  - name: cart
+    conditions:
+    - prefix: /cart
+`, `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: cart
+  namespace: shop
+spec:
+  routes:
+  - conditions:
+    - prefix: /
+    services:
+    - name: cart
+      port: 80
+`)
+
+	want := map[string][]string{
+		"root": {"/blog/", "/shop"},
+		"blog": {"/blog/", "/blog/admin"},
+		"shop": {"/shop/cart"},
+		"cart": {"/shop/cart/"},
+	}
+
+	for _, translation := range HTTPProxiesToIngressRoutes(hps) {
+		if translation.Err != nil {
+			t.Fatal(translation.Err)
+		}
+		if len(translation.Warnings) > 0 {
+			t.Fatalf("%s: unexpected warnings: %v", translation.HTTPProxy.Name, translation.Warnings)
+		}
+		var matches []string
+		for _, route := range translation.IngressRoute.Spec.Routes {
+			matches = append(matches, route.Match)
+		}
+		if diff := cmp.Diff(matches, want[translation.HTTPProxy.Name]); diff != "" {
+			t.Errorf("%s: matches mismatch:\n%v", translation.HTTPProxy.Name, diff)
+		}
+	}
+}
+
+func TestJoinPrefixes(t *testing.T) {
+
+	tests := map[string]struct {
+		includePrefix string
+		prefix        string
+		want          string
+	}{
+		"no include prefix": {
+			prefix: "/admin",
+			want:   "/admin",
+		},
+		"doubled slash": {
+			includePrefix: "/blog/",
+			prefix:        "/admin",
+			want:          "/blog/admin",
+		},
+		"run of slashes": {
+			includePrefix: "//",
+			prefix:        "/",
+			want:          "/",
+		},
+		"run of slashes in the middle": {
+			includePrefix: "/blog//",
+			prefix:        "//admin",
+			want:          "/blog/admin",
+		},
+		"both empty": {
+			want: "/",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := joinPrefixes(tc.includePrefix, tc.prefix); got != tc.want {
+				t.Fatalf("expected: %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHTTPProxyToIngressRouteErrors(t *testing.T) {
+
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"header condition": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - conditions:
+    - header:
+        name: x-canary
+        present: true
+    services:
+    - name: s1
+      port: 80
+`,
+			want: "can't translate HTTPProxy default/example: a route has a header condition on x-canary, which IngressRoute does not support",
+		},
+		"multiple conditions": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - conditions:
+    - prefix: /foo
+    - header:
+        name: x-canary
+        present: true
+    services:
+    - name: s1
+      port: 80
+`,
+			want: "can't translate HTTPProxy default/example: a route has more than one condition, which IngressRoute does not support",
+		},
+		"include header condition": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  includes:
+  - name: child
+    conditions:
+    - header:
+        name: x-canary
+        present: true
+`,
+			want: "can't translate HTTPProxy default/example: the include of child has a header condition on x-canary, which IngressRoute does not support",
+		},
+		"headers policy": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - conditions:
+    - prefix: /foo
+    requestHeadersPolicy:
+      remove:
+      - x-debug
+    services:
+    - name: s1
+      port: 80
+`,
+			want: "can't translate HTTPProxy default/example: route /foo has a headers policy, which IngressRoute does not support",
+		},
+		"idle timeout": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - timeoutPolicy:
+      idle: 10s
+    services:
+    - name: s1
+      port: 80
+`,
+			want: "can't translate HTTPProxy default/example: route / has an idle timeout, which IngressRoute does not support",
+		},
+		"prefix specific rewrite": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - pathRewritePolicy:
+      replacePrefix:
+      - prefix: /foo
+        replacement: /bar
+    services:
+    - name: s1
+      port: 80
+`,
+			want: "can't translate HTTPProxy default/example: route / has a path rewrite for a specific prefix, which IngressRoute does not support",
+		},
+		"mirror": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - services:
+    - name: s1
+      port: 80
+    - name: s2
+      port: 80
+      mirror: true
+`,
+			want: "can't translate HTTPProxy default/example: route /: service s2 has mirroring, which IngressRoute does not support",
+		},
+		"protocol": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+  routes:
+  - services:
+    - name: s1
+      port: 80
+      protocol: h2c
+`,
+			want: "can't translate HTTPProxy default/example: route /: service s1 has a protocol, which IngressRoute does not support",
+		},
+		"tcpproxy include and services": {
+			input: `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: example
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: foo.bar.com
+    tls:
+      passthrough: true
+  tcpproxy:
+    includes:
+      name: child
+    services:
+    - name: s1
+      port: 443
+`,
+			want: "can't translate HTTPProxy default/example: tcpproxy has both an include and services, which IngressRoute does not support",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			hps := decodeHTTPProxies(t, tc.input)
+			_, _, err := HTTPProxyToIngressRoute(hps[0])
+			if err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if diff := cmp.Diff(err.Error(), tc.want); diff != "" {
+				t.Fatalf("Error mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestHTTPProxiesToIngressRoutesIncludedTwice(t *testing.T) {
+
+	hps := decodeHTTPProxies(t, `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: root.bar.com
+  includes:
+  - name: child
+    conditions:
+    - prefix: /a
+  - name: child
+    conditions:
+    - prefix: /b
+`, `
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: child
+  namespace: default
+spec:
+  routes:
+  - services:
+    - name: s1
+      port: 80
+`)
+
+	translations := HTTPProxiesToIngressRoutes(hps)
+	if translations[0].Err != nil {
+		t.Fatal(translations[0].Err)
+	}
+	want := "can't translate HTTPProxy default/child: it is included at more than one path (/a, /b), and IngressRoute matches can only include one delegation prefix"
+	if translations[1].Err == nil {
+		t.Fatal("expected an error, got none")
+	}
+	if diff := cmp.Diff(translations[1].Err.Error(), want); diff != "" {
+		t.Fatalf("Error mismatch:\n%v", diff)
+	}
+}
+
+// TestReverseTranslateFixtures checks that translating the HTTPProxy output for
+// each IngressRoute fixture back to an IngressRoute, and then forward again,
+// gives the same HTTPProxy.
+func TestReverseTranslateFixtures(t *testing.T) {
+	for name, tc := range buildFixtureSet(t) {
+		t.Run(name, func(t *testing.T) {
+			items, err := k8sdecoder.Decode(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ir, ok := items[0].Object.(*irv1beta1.IngressRoute)
+			if !ok {
+				t.Skipf("input is a %s, not an IngressRoute", items[0].GroupVersionKind)
+			}
+
+			// Use the same include prefix both ways.
+			var includePrefix string
+			if ir.Spec.VirtualHost == nil {
+				includePrefix, _, err = guessIncludePrefix(ir.Spec.Routes)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			hp, _, err := translateIngressRoute(ir, includePrefix, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reversed, err := translateHTTPProxy(hp, includePrefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roundTripped, _, err := translateIngressRoute(reversed, includePrefix, true, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(roundTripped, hp); diff != "" {
+				t.Fatalf("Round trip failure:\n%v", diff)
+			}
+		})
+	}
+}
+
+func decodeHTTPProxies(t *testing.T, inputs ...string) []*hpv1.HTTPProxy {
+	var hps []*hpv1.HTTPProxy
+	for _, input := range inputs {
+		hp := &hpv1.HTTPProxy{}
+		if err := yaml.Unmarshal([]byte(input), hp); err != nil {
+			t.Fatal(err)
+		}
+		hps = append(hps, hp)
+	}
+	return hps
+}
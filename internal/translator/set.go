@@ -0,0 +1,75 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"strings"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/delegation"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+)
+
+// Translation is the result of translating one IngressRoute in a set.
+type Translation struct {
+	IngressRoute *irv1beta1.IngressRoute
+	HTTPProxy    *hpv1.HTTPProxy
+	Warnings     []warning.Warning
+	Err          error
+}
+
+// IngressRoutesToHTTPProxies translates a set of IngressRoute objects to HTTPProxy ones,
+// returning a Translation for each, in the same order.
+// Unlike IngressRouteToHTTPProxy, the include prefix of a nonroot IngressRoute is taken
+// from the route that delegates to it. It's only guessed if no IngressRoute in the set
+// delegates to it, or if it's delegated to at more than one path.
+// A nonroot IngressRoute can have a tcpproxy if the tcpproxy of another one in
+// the set delegates to it.
+func IngressRoutesToHTTPProxies(irs []*irv1beta1.IngressRoute) []Translation {
+
+	graph := delegation.Build(irs)
+
+	translations := make([]Translation, 0, len(irs))
+	for _, ir := range irs {
+
+		var warnings []warning.Warning
+		var includePrefix string
+		var prefixKnown bool
+
+		if ir.Spec.VirtualHost == nil {
+			prefixes := graph.IncludePrefixes(delegation.KeyOf(ir))
+			switch len(prefixes) {
+			case 0:
+				// The parent isn't in the set, so fall back to guessing.
+			case 1:
+				includePrefix = prefixes[0]
+				prefixKnown = true
+			default:
+				warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IncludePrefixMultiple, "",
+					"IngressRoute is delegated to at more than one path (%s), so the include path can't be determined exactly. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct.", strings.Join(prefixes, ", ")).WithDocs(conditionsDocsURL))
+			}
+		}
+
+		hp, translateWarnings, err := translateIngressRoute(ir, includePrefix, prefixKnown, graph.IsTCPProxyDelegated(delegation.KeyOf(ir)))
+		translations = append(translations, Translation{
+			IngressRoute: ir,
+			HTTPProxy:    hp,
+			Warnings:     warning.WithObject(append(warnings, translateWarnings...), ingressRouteObject(ir)),
+			Err:          err,
+		})
+	}
+
+	return translations
+}
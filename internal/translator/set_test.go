@@ -0,0 +1,403 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+)
+
+const setRoot = `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: root.bar.com
+  routes:
+    - match: /
+      services:
+        - name: s1
+          port: 80
+    - match: /service2
+      delegate:
+        name: service2
+`
+
+const setService2 = `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: service2
+  namespace: default
+spec:
+  routes:
+    - match: /service2
+      services:
+        - name: s2
+          port: 80
+    - match: /service2/blog
+      services:
+        - name: blog
+          port: 80
+    - match: /service2/api
+      delegate:
+        name: api
+        namespace: api
+`
+
+const setAPI = `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: api
+  namespace: api
+spec:
+  routes:
+    - match: /service2/api/v1
+      services:
+        - name: api-v1
+          port: 80
+`
+
+const setTCPProxyRoot = `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: tcp-root
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: tcp.bar.com
+    tls:
+      passthrough: true
+  tcpproxy:
+    delegate:
+      name: tcp-child
+      namespace: tcp
+`
+
+const setTCPProxyChild = `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: tcp-child
+  namespace: tcp
+spec:
+  tcpproxy:
+    services:
+      - name: backend
+        port: 443
+`
+
+func TestIngressRoutesToHTTPProxies(t *testing.T) {
+
+	tests := map[string]struct {
+		inputs   []string
+		want     string
+		warnings [][]string
+	}{
+		"nested delegation": {
+			inputs: []string{setRoot, setService2, setAPI},
+			want: `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: root
+  namespace: default
+spec:
+  includes:
+  - conditions:
+    - prefix: /service2
+    name: service2
+  routes:
+  - conditions:
+    - prefix: /
+    services:
+    - name: s1
+      port: 80
+  virtualhost:
+    fqdn: root.bar.com
+status: {}
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: service2
+  namespace: default
+spec:
+  includes:
+  - conditions:
+    - prefix: /api
+    name: api
+    namespace: api
+  routes:
+  - services:
+    - name: s2
+      port: 80
+  - conditions:
+    - prefix: /blog
+    services:
+    - name: blog
+      port: 80
+status: {}
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: api
+  namespace: api
+spec:
+  routes:
+  - conditions:
+    - prefix: /v1
+    services:
+    - name: api-v1
+      port: 80
+status: {}`,
+			warnings: [][]string{nil, nil, nil},
+		},
+		"tcpproxy delegation": {
+			inputs: []string{setTCPProxyRoot, setTCPProxyChild},
+			want: `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: tcp-root
+  namespace: default
+spec:
+  tcpproxy:
+    includes:
+      name: tcp-child
+      namespace: tcp
+  virtualhost:
+    fqdn: tcp.bar.com
+    tls:
+      passthrough: true
+status: {}
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: tcp-child
+  namespace: tcp
+spec:
+  tcpproxy:
+    services:
+    - name: backend
+      port: 443
+status: {}`,
+			warnings: [][]string{nil, nil},
+		},
+		"parent not in the set": {
+			inputs: []string{setAPI},
+			want: `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: api
+  namespace: api
+spec:
+  routes:
+  - conditions:
+    - prefix: /service2/api/v1
+    services:
+    - name: api-v1
+      port: 80
+status: {}`,
+			warnings: [][]string{
+				{"Can't determine include path from single match /service2/api/v1. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct. See https://projectcontour.io/docs/main/httpproxy/#conditions-and-inclusion"},
+			},
+		},
+		"delegated at more than one path": {
+			inputs: []string{setRoot, `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: root2
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: root2.bar.com
+  routes:
+    - match: /other
+      delegate:
+        name: service2
+`, `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: service2
+  namespace: default
+spec:
+  routes:
+    - match: /service2/blog
+      services:
+        - name: blog
+          port: 80
+    - match: /service2/shop
+      services:
+        - name: shop
+          port: 80
+`},
+			want: `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: root
+  namespace: default
+spec:
+  includes:
+  - conditions:
+    - prefix: /service2
+    name: service2
+  routes:
+  - conditions:
+    - prefix: /
+    services:
+    - name: s1
+      port: 80
+  virtualhost:
+    fqdn: root.bar.com
+status: {}
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: root2
+  namespace: default
+spec:
+  includes:
+  - conditions:
+    - prefix: /other
+    name: service2
+  virtualhost:
+    fqdn: root2.bar.com
+status: {}
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: service2
+  namespace: default
+spec:
+  routes:
+  - conditions:
+    - prefix: /blog
+    services:
+    - name: blog
+      port: 80
+  - conditions:
+    - prefix: /shop
+    services:
+    - name: shop
+      port: 80
+status: {}`,
+			warnings: [][]string{nil, nil, {
+				"IngressRoute is delegated to at more than one path (/service2, /other), so the include path can't be determined exactly. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct. See https://projectcontour.io/docs/main/httpproxy/#conditions-and-inclusion",
+				"The guess for the IngressRoute include path is /service2. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct. See https://projectcontour.io/docs/main/httpproxy/#conditions-and-inclusion",
+			}},
+		},
+		"match not on a path boundary": {
+			inputs: []string{setRoot, `
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: service2
+  namespace: default
+spec:
+  routes:
+    - match: /service2beta
+      services:
+        - name: s2
+          port: 80
+`},
+			want: `apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: root
+  namespace: default
+spec:
+  includes:
+  - conditions:
+    - prefix: /service2
+    name: service2
+  routes:
+  - conditions:
+    - prefix: /
+    services:
+    - name: s1
+      port: 80
+  virtualhost:
+    fqdn: root.bar.com
+status: {}
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: service2
+  namespace: default
+spec:
+  routes:
+  - conditions:
+    - prefix: /beta
+    services:
+    - name: s2
+      port: 80
+status: {}`,
+			warnings: [][]string{nil, {
+				"Match /service2beta can't be represented exactly under the include path /service2. HTTPProxy prefix conditions must start with / and should not include the include prefix. Please check this value is correct. See https://projectcontour.io/docs/main/httpproxy/#conditions-and-inclusion",
+			}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var irs []*irv1beta1.IngressRoute
+			for _, input := range tc.inputs {
+				ir, err := k8sdecoder.DecodeIngressRoute([]byte(input))
+				if err != nil {
+					t.Fatal(err)
+				}
+				irs = append(irs, ir)
+			}
+
+			var outputs [][]byte
+			var warnings [][]string
+			for _, translation := range IngressRoutesToHTTPProxies(irs) {
+				if translation.Err != nil {
+					t.Fatal(translation.Err)
+				}
+				outputYAML, err := yaml.Marshal(translation.HTTPProxy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				outputYAML = bytes.ReplaceAll(outputYAML, []byte("  creationTimestamp: null\n"), []byte(""))
+				outputs = append(outputs, bytes.TrimSpace(outputYAML))
+				warnings = append(warnings, warning.Strings(translation.Warnings))
+			}
+
+			if diff := cmp.Diff(string(bytes.Join(outputs, []byte("\n---\n"))), tc.want); diff != "" {
+				t.Fatalf("Translation failure:\n%v", diff)
+			}
+			if diff := cmp.Diff(warnings, tc.warnings); diff != "" {
+				t.Fatalf("Translation Warnings Mismatch:\n%v", diff)
+			}
+		})
+	}
+}
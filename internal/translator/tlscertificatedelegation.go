@@ -0,0 +1,63 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translator
+
+import (
+	"fmt"
+
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TLSCertificateDelegationToV1 translates contour.heptio.com/v1beta1 TLSCertificateDelegation
+// objects to projectcontour.io/v1 ones, which are the ones HTTPProxy uses.
+func TLSCertificateDelegationToV1(tcd *irv1beta1.TLSCertificateDelegation) (*hpv1.TLSCertificateDelegation, []warning.Warning) {
+
+	var warnings []warning.Warning
+
+	var delegations []hpv1.CertificateDelegation
+	for index, delegation := range tcd.Spec.Delegations {
+		if len(delegation.TargetNamespaces) == 0 {
+			warnings = append(warnings, warning.New(warning.SeverityInfo, warning.TLSDelegationNoTargets, fmt.Sprintf(".spec.delegations[%d].targetNamespaces", index),
+				"Delegation of secret %s has no targetNamespaces, so it doesn't delegate the secret to anything. Please check this value is correct.", delegation.SecretName))
+		}
+		// targetNamespaces is required, so make sure it's never output as null.
+		targetNamespaces := make([]string, len(delegation.TargetNamespaces))
+		copy(targetNamespaces, delegation.TargetNamespaces)
+		delegations = append(delegations, hpv1.CertificateDelegation{
+			SecretName:       delegation.SecretName,
+			TargetNamespaces: targetNamespaces,
+		})
+	}
+
+	return &hpv1.TLSCertificateDelegation{
+		TypeMeta: v1.TypeMeta{
+			Kind:       "TLSCertificateDelegation",
+			APIVersion: "projectcontour.io/v1",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			// As with HTTPProxy, the zero CreationTimestamp is filtered out of the
+			// marshaled YAML before it's output.
+			Name:        tcd.ObjectMeta.Name,
+			Namespace:   tcd.ObjectMeta.Namespace,
+			Labels:      tcd.ObjectMeta.DeepCopy().GetLabels(),
+			Annotations: tcd.ObjectMeta.DeepCopy().GetAnnotations(),
+		},
+		Spec: hpv1.TLSCertificateDelegationSpec{
+			Delegations: delegations,
+		},
+	}, warning.WithObject(warnings, warning.Object{Kind: "TLSCertificateDelegation", Namespace: tcd.Namespace, Name: tcd.Name})
+}
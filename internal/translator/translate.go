@@ -19,64 +19,84 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
 	hpv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/ir2proxy/internal/warning"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// conditionsDocsURL documents how HTTPProxy include conditions work.
+const conditionsDocsURL = "https://projectcontour.io/docs/main/httpproxy/#conditions-and-inclusion"
+
 // IngressRouteToHTTPProxy translates IngressRoute objects to HTTPProxy ones, emitting warnings
 // as it goes.
-// There are currently no fatal conditions (that should not produces a HTTPProxy output)
-// TODO(youngnick) - change this signature to return HTTPProxy, []string, error if we need that.
-func IngressRouteToHTTPProxy(ir *irv1beta1.IngressRoute) (*hpv1.HTTPProxy, []string, error) {
+// It returns an error if the IngressRoute is invalid and can't be translated, like a route
+// with both a delegate and services, or a nonroot IngressRoute whose matches don't share a prefix.
+func IngressRouteToHTTPProxy(ir *irv1beta1.IngressRoute) (*hpv1.HTTPProxy, []warning.Warning, error) {
+	return translateIngressRoute(ir, "", false, false)
+}
+
+// ingressRouteObject returns the warning.Object for an IngressRoute.
+func ingressRouteObject(ir *irv1beta1.IngressRoute) warning.Object {
+	return warning.Object{Kind: "IngressRoute", Namespace: ir.Namespace, Name: ir.Name}
+}
+
+// translateIngressRoute translates a single IngressRoute.
+// If prefixKnown is true, includePrefix is the prefix that a nonroot IngressRoute is
+// delegated to at, and is trimmed from its matches. Otherwise, the include prefix is
+// guessed from the matches.
+// If tcpproxyDelegated is true, the tcpproxy of another IngressRoute delegates
+// to this one, so a nonroot IngressRoute can have a tcpproxy too.
+func translateIngressRoute(ir *irv1beta1.IngressRoute, includePrefix string, prefixKnown bool, tcpproxyDelegated bool) (*hpv1.HTTPProxy, []warning.Warning, error) {
 
 	// TODO(youngnick): Investigate if we should skip logically empty IngressRoutes
 
 	var routeLCP string
-	var warnings []string
+	var warnings []warning.Warning
 
 	var tcpproxy *hpv1.TCPProxy
 
 	var includes []hpv1.Include
 
 	if ir.Spec.TCPProxy != nil {
-		if ir.Spec.VirtualHost == nil {
-			return nil, nil, errors.New("invalid IngressRoute: tcpproxy must be in a root IngressRoute")
+		if ir.Spec.VirtualHost == nil && !tcpproxyDelegated {
+			return nil, nil, errors.New("invalid IngressRoute: tcpproxy must be in a root IngressRoute, or one that a tcpproxy delegates to")
 		}
 
 		// The compiler won't use the outer tcpproxy correctly if we
 		// use := here.
-		var tcpincludes []hpv1.Include
 		var err error
-		var tcpwarnings []string
-		tcpproxy, tcpincludes, tcpwarnings, err = translateTCPProxy(ir.Spec.TCPProxy)
+		var tcpwarnings []warning.Warning
+		tcpproxy, tcpwarnings, err = translateTCPProxy(ir.Spec.TCPProxy)
 		if err != nil {
 			return nil, nil, err
 		}
-		includes = append(includes, tcpincludes...)
 		warnings = append(warnings, tcpwarnings...)
 	}
 
-	if ir.Spec.VirtualHost == nil {
-		routePrefixes := extractPrefixes(ir.Spec.Routes)
-		routeLCP = longestCommonPathPrefix(routePrefixes)
-		if routeLCP == "" && len(routePrefixes) > 1 {
-			// There are no common prefixes here.
-			return nil, nil, errors.New("invalid IngressRoute: match clauses must share a common prefix")
-		}
-		if len(routePrefixes) == 1 && routePrefixes[0] != "/" {
-			warnings = append(warnings, fmt.Sprintf("Can't determine include path from single match %s. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct. See https://projectcontour.io/docs/main/httpproxy/#conditions-and-inclusion", routePrefixes[0]))
-			// Reset the largest common prefix back to '/', since we can't replace it.
-			routeLCP = ""
-		}
-		if routeLCP != "" {
-			warnings = append(warnings, fmt.Sprintf("The guess for the IngressRoute include path is %s. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct. See https://projectcontour.io/docs/main/httpproxy/#conditions-and-inclusion", routeLCP))
+	if ir.Spec.VirtualHost == nil && prefixKnown {
+		// A prefix of '/' doesn't need trimming.
+		if includePrefix != "/" {
+			routeLCP = includePrefix
 		}
+	}
 
+	if ir.Spec.VirtualHost == nil && !prefixKnown {
+		var guessWarnings []warning.Warning
+		var err error
+		routeLCP, guessWarnings, err = guessIncludePrefix(ir.Spec.Routes)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, guessWarnings...)
 	}
 
-	routes, routeIncludes, translateWarnings := translateRoutes(ir.Spec.Routes, routeLCP)
+	routes, routeIncludes, translateWarnings, err := translateRoutes(ir.Spec.Routes, routeLCP)
+	if err != nil {
+		return nil, nil, err
+	}
 	includes = append(includes, routeIncludes...)
 	warnings = append(warnings, translateWarnings...)
 
@@ -104,27 +124,72 @@ func IngressRouteToHTTPProxy(ir *irv1beta1.IngressRoute) (*hpv1.HTTPProxy, []str
 		},
 	}
 
-	return hp, warnings, nil
+	return hp, warning.WithObject(warnings, ingressRouteObject(ir)), nil
 }
 
-func translateRoute(irRoute irv1beta1.Route, routeLCP string) (hpv1.Route, []string) {
+// guessIncludePrefix guesses the include prefix of a nonroot IngressRoute from
+// the longest common prefix of its matches.
+// The empty string means that there is no prefix to trim.
+func guessIncludePrefix(routes []irv1beta1.Route) (string, []warning.Warning, error) {
 
-	var warnings []string
+	var warnings []warning.Warning
 
-	route := hpv1.Route{
-		Conditions: []hpv1.Condition{
-			hpv1.Condition{},
-		},
+	routePrefixes := extractPrefixes(routes)
+	routeLCP := longestCommonPathPrefix(routePrefixes)
+	if routeLCP == "" && len(routePrefixes) > 1 && !allRootPrefixes(routePrefixes) {
+		// There are no common prefixes here.
+		return "", nil, errors.New("invalid IngressRoute: match clauses must share a common prefix")
+	}
+	if len(routePrefixes) == 1 && routePrefixes[0] != "/" {
+		warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IncludePrefixSingleMatch, ".spec.routes[0].match",
+			"Can't determine include path from single match %s. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct.", routePrefixes[0]).WithDocs(conditionsDocsURL))
+		// Reset the largest common prefix back to '/', since we can't replace it.
+		routeLCP = ""
+	}
+	if routeLCP != "" {
+		warnings = append(warnings, warning.New(warning.SeverityWarning, warning.IncludePrefixGuess, ".spec.routes",
+			"The guess for the IngressRoute include path is %s. HTTPProxy prefix conditions should not include the include prefix. Please check this value is correct.", routeLCP).WithDocs(conditionsDocsURL))
 	}
 
+	return routeLCP, warnings, nil
+}
+
+// allRootPrefixes returns true if every prefix matches all paths, so that
+// there's no include prefix to trim.
+func allRootPrefixes(prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix != "" && prefix != "/" {
+			return false
+		}
+	}
+	return true
+}
+
+// translateRoute translates the route at path in an IngressRoute.
+func translateRoute(irRoute irv1beta1.Route, routeLCP string, path string) (hpv1.Route, []warning.Warning, error) {
+
+	var warnings []warning.Warning
+
+	var route hpv1.Route
+
 	// If we've been passed a largest common prefix for all the routes, trim it
 	// off the Match.
 	// Note that the empty string for routeLCP here means "no prefix".
-	match := irRoute.Match
-	if routeLCP != "" {
-		match = strings.TrimPrefix(match, routeLCP)
+	match, exact := trimIncludePrefix(irRoute.Match, routeLCP)
+	if !exact {
+		warnings = append(warnings, includePrefixWarning(irRoute.Match, routeLCP, path))
+	}
+	// A match that's the same as the include prefix needs no conditions.
+	if match != "" || routeLCP == "" {
+		route.Conditions = []hpv1.Condition{
+			hpv1.Condition{
+				Prefix: match,
+			},
+		}
 	}
-	route.Conditions[0].Prefix = match
+
+	route.EnableWebsockets = irRoute.EnableWebsockets
+	route.PermitInsecure = irRoute.PermitInsecure
 
 	if irRoute.TimeoutPolicy != nil {
 		route.TimeoutPolicy = &hpv1.TimeoutPolicy{
@@ -132,6 +197,12 @@ func translateRoute(irRoute irv1beta1.Route, routeLCP string) (hpv1.Route, []str
 		}
 	}
 
+	if irRoute.RetryPolicy != nil {
+		retryPolicy, retryWarnings := translateRetryPolicy(irRoute.RetryPolicy, irRoute.Match, path+".retryPolicy")
+		route.RetryPolicy = retryPolicy
+		warnings = append(warnings, retryWarnings...)
+	}
+
 	if irRoute.PrefixRewrite != "" {
 		route.PathRewritePolicy = &hpv1.PathRewritePolicy{
 			ReplacePrefix: []hpv1.ReplacePrefix{
@@ -145,9 +216,13 @@ func translateRoute(irRoute irv1beta1.Route, routeLCP string) (hpv1.Route, []str
 	var seenLBStrategy string
 	var seenHealthCheckPolicy *irv1beta1.HealthCheck
 	var seenHealthCheckServiceName string
-	for _, irService := range irRoute.Services {
+	for index, irService := range irRoute.Services {
+		servicePath := fmt.Sprintf("%s.services[%d]", path, index)
 
-		service, healthcheckPolicy, lbpolicy := translateService(irService)
+		service, healthcheckPolicy, lbpolicy, err := translateService(irService)
+		if err != nil {
+			return hpv1.Route{}, nil, err
+		}
 
 		if lbpolicy != nil {
 			if seenLBStrategy == "" {
@@ -157,7 +232,8 @@ func translateRoute(irRoute irv1beta1.Route, routeLCP string) (hpv1.Route, []str
 				route.LoadBalancerPolicy = lbpolicy
 			} else {
 				if seenLBStrategy != irService.Strategy {
-					warnings = append(warnings, fmt.Sprintf("Strategy %s on Service %s could not be applied, HTTPProxy only supports a single load balancing policy across all services. %s is already applied.", irService.Strategy, irService.Name, seenLBStrategy))
+					warnings = append(warnings, warning.New(warning.SeverityWarning, warning.LBConflict, servicePath+".strategy",
+						"Strategy %s on Service %s could not be applied, HTTPProxy only supports a single load balancing policy across all services. %s is already applied.", irService.Strategy, irService.Name, seenLBStrategy))
 				}
 			}
 		}
@@ -170,23 +246,64 @@ func translateRoute(irRoute irv1beta1.Route, routeLCP string) (hpv1.Route, []str
 				seenHealthCheckServiceName = irService.Name
 				route.HealthCheckPolicy = healthcheckPolicy
 			} else {
-				warnings = append(warnings, fmt.Sprintf("A healthcheck on service %s could not be applied, HTTPProxy only supports a single healthcheck across all services. A different healthcheck from service %s is already applied.", irService.Name, seenHealthCheckServiceName))
+				warnings = append(warnings, warning.New(warning.SeverityWarning, warning.HealthCheckConflict, servicePath+".healthCheck",
+					"A healthcheck on service %s could not be applied, HTTPProxy only supports a single healthcheck across all services. A different healthcheck from service %s is already applied.", irService.Name, seenHealthCheckServiceName))
 			}
 		}
 
 		route.Services = append(route.Services, service)
 	}
 
-	return route, warnings
+	return route, warnings, nil
+}
+
+func translateRetryPolicy(irRetryPolicy *hpv1.RetryPolicy, match string, path string) (*hpv1.RetryPolicy, []warning.Warning) {
+
+	var warnings []warning.Warning
+
+	retryPolicy := &hpv1.RetryPolicy{
+		NumRetries:    irRetryPolicy.NumRetries,
+		PerTryTimeout: irRetryPolicy.PerTryTimeout,
+	}
+
+	// Contour ignores a perTryTimeout it can't parse, or that's negative, so
+	// rather than carry an ignored value over, drop it and say so.
+	if irRetryPolicy.PerTryTimeout != "" {
+		perTryTimeout, err := time.ParseDuration(irRetryPolicy.PerTryTimeout)
+		switch {
+		case err != nil:
+			warnings = append(warnings, warning.New(warning.SeverityInfo, warning.RetryTimeoutInvalid, path+".perTryTimeout",
+				"perTryTimeout %s on route %s is not a valid duration and was ignored by Contour, discarding. Please check the retry policy is correct.", irRetryPolicy.PerTryTimeout, match))
+			retryPolicy.PerTryTimeout = ""
+		case perTryTimeout < 0:
+			warnings = append(warnings, warning.New(warning.SeverityInfo, warning.RetryTimeoutInvalid, path+".perTryTimeout",
+				"perTryTimeout %s on route %s is negative and was ignored by Contour, discarding. Please check the retry policy is correct.", irRetryPolicy.PerTryTimeout, match))
+			retryPolicy.PerTryTimeout = ""
+		}
+	}
+
+	return retryPolicy, warnings
 }
 
-func translateService(irService irv1beta1.Service) (hpv1.Service, *hpv1.HTTPHealthCheckPolicy, *hpv1.LoadBalancerPolicy) {
+func translateService(irService irv1beta1.Service) (hpv1.Service, *hpv1.HTTPHealthCheckPolicy, *hpv1.LoadBalancerPolicy, error) {
 	service := hpv1.Service{
 		Name:   irService.Name,
 		Port:   irService.Port,
 		Weight: irService.Weight,
 	}
 
+	if irService.UpstreamValidation != nil {
+		// HTTPProxy needs both fields to verify the upstream. Rather than output
+		// a HTTPProxy that talks to the upstream without verifying it, stop here.
+		if irService.UpstreamValidation.CACertificate == "" || irService.UpstreamValidation.SubjectName == "" {
+			return hpv1.Service{}, nil, nil, fmt.Errorf("invalid IngressRoute: validation on service %s must have both caSecret and subjectName set", irService.Name)
+		}
+		service.UpstreamValidation = &hpv1.UpstreamValidation{
+			CACertificate: irService.UpstreamValidation.CACertificate,
+			SubjectName:   irService.UpstreamValidation.SubjectName,
+		}
+	}
+
 	var healthcheckPolicy *hpv1.HTTPHealthCheckPolicy
 	var lbpolicy *hpv1.LoadBalancerPolicy
 
@@ -200,74 +317,147 @@ func translateService(irService irv1beta1.Service) (hpv1.Service, *hpv1.HTTPHeal
 		healthcheckPolicy = &hpv1.HTTPHealthCheckPolicy{
 			Path:                    irService.HealthCheck.Path,
 			Host:                    irService.HealthCheck.Host,
+			IntervalSeconds:         irService.HealthCheck.IntervalSeconds,
 			TimeoutSeconds:          irService.HealthCheck.TimeoutSeconds,
 			UnhealthyThresholdCount: irService.HealthCheck.UnhealthyThresholdCount,
 			HealthyThresholdCount:   irService.HealthCheck.HealthyThresholdCount,
 		}
 	}
 
-	return service, healthcheckPolicy, lbpolicy
+	return service, healthcheckPolicy, lbpolicy, nil
 }
 
-func translateInclude(irRoute irv1beta1.Route) *hpv1.Include {
+// translateInclude translates the delegating route at path in an IngressRoute.
+func translateInclude(irRoute irv1beta1.Route, routeLCP string, path string) (*hpv1.Include, []warning.Warning) {
 
 	if irRoute.Delegate == nil {
-		return nil
+		return nil, nil
 	}
 
-	return &hpv1.Include{
-		Conditions: []hpv1.Condition{
-			hpv1.Condition{
-				Prefix: irRoute.Match,
-			},
-		},
+	var warnings []warning.Warning
+
+	// The include conditions of a nonroot HTTPProxy are relative to its own
+	// include prefix, the same as its route conditions.
+	match, exact := trimIncludePrefix(irRoute.Match, routeLCP)
+	if !exact {
+		warnings = append(warnings, includePrefixWarning(irRoute.Match, routeLCP, path))
+	}
+
+	include := &hpv1.Include{
 		Name:      irRoute.Delegate.Name,
 		Namespace: irRoute.Delegate.Namespace,
 	}
+	if match != "" || routeLCP == "" {
+		include.Conditions = []hpv1.Condition{
+			hpv1.Condition{
+				Prefix: match,
+			},
+		}
+	}
+
+	return include, warnings
 }
 
-func translateRoutes(irRoutes []irv1beta1.Route, routeLCP string) ([]hpv1.Route, []hpv1.Include, []string) {
+// trimIncludePrefix removes the include prefix from a match, since HTTPProxy
+// prepends the include conditions to the conditions of the included routes.
+// It returns false if the match can't be represented exactly under the prefix.
+func trimIncludePrefix(match string, prefix string) (string, bool) {
+
+	if prefix == "" {
+		return match, true
+	}
+
+	if !strings.HasPrefix(match, prefix) {
+		return match, false
+	}
+
+	trimmed := strings.TrimPrefix(match, prefix)
+	if trimmed == "" || trimmed[0] == '/' {
+		return trimmed, true
+	}
+
+	// Contour collapses the '//' when the prefixes are joined.
+	if strings.HasSuffix(prefix, "/") {
+		return "/" + trimmed, true
+	}
+
+	// HTTPProxy prefix conditions must start with '/', so something
+	// like /foobar under /foo can only be approximated.
+	return "/" + trimmed, false
+}
+
+// includePrefixWarning warns that the match of the route at path can't be
+// represented exactly under the include prefix.
+func includePrefixWarning(match string, prefix string, path string) warning.Warning {
+	return warning.New(warning.SeverityWarning, warning.IncludePrefixInexact, path+".match",
+		"Match %s can't be represented exactly under the include path %s. HTTPProxy prefix conditions must start with / and should not include the include prefix. Please check this value is correct.", match, prefix).WithDocs(conditionsDocsURL)
+}
+
+func translateRoutes(irRoutes []irv1beta1.Route, routeLCP string) ([]hpv1.Route, []hpv1.Include, []warning.Warning, error) {
 
 	var routes []hpv1.Route
 	var includes []hpv1.Include
-	var warnings []string
-	for _, irRoute := range irRoutes {
-		hpInclude := translateInclude(irRoute)
+	var warnings []warning.Warning
+	for index, irRoute := range irRoutes {
+		path := fmt.Sprintf(".spec.routes[%d]", index)
+		hpInclude, includeWarnings := translateInclude(irRoute, routeLCP, path)
 		if hpInclude != nil {
 			includes = append(includes, *hpInclude)
+			warnings = append(warnings, includeWarnings...)
+			// In IngressRoute, these flags on a delegating route were not passed on to
+			// the delegated routes. HTTPProxy includes have no equivalent fields,
+			// so the included HTTPProxy needs to set them on its own routes.
+			if irRoute.EnableWebsockets {
+				warnings = append(warnings, warning.New(warning.SeverityWarning, warning.DelegateWebsockets, path+".enableWebsockets",
+					"enableWebsockets on the route delegating %s to %s could not be applied, HTTPProxy includes do not support it. Set enableWebsockets on the routes of the included HTTPProxy instead.", irRoute.Match, irRoute.Delegate.Name))
+			}
+			if irRoute.PermitInsecure {
+				warnings = append(warnings, warning.New(warning.SeverityWarning, warning.DelegatePermitInsecure, path+".permitInsecure",
+					"permitInsecure on the route delegating %s to %s could not be applied, HTTPProxy includes do not support it. Set permitInsecure on the routes of the included HTTPProxy instead.", irRoute.Match, irRoute.Delegate.Name))
+			}
 			continue
 		}
-		route, translationWarnings := translateRoute(irRoute, routeLCP)
+		route, translationWarnings, err := translateRoute(irRoute, routeLCP, path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
 		routes = append(routes, route)
 		warnings = append(warnings, translationWarnings...)
 	}
 
-	return routes, includes, warnings
+	return routes, includes, warnings, nil
 }
 
-func translateTCPProxy(irTCPProxy *irv1beta1.TCPProxy) (*hpv1.TCPProxy, []hpv1.Include, []string, error) {
+func translateTCPProxy(irTCPProxy *irv1beta1.TCPProxy) (*hpv1.TCPProxy, []warning.Warning, error) {
 
-	var includes []hpv1.Include
-	var warnings []string
+	var warnings []warning.Warning
 
 	if irTCPProxy.Delegate != nil {
 		if len(irTCPProxy.Services) > 0 {
-			return nil, includes, warnings, errors.New("invalid IngressRoute: Delegate and Services can not both be set")
+			return nil, warnings, errors.New("invalid IngressRoute: Delegate and Services can not both be set")
 		}
-		includes = append(includes, hpv1.Include{
-			Name:      irTCPProxy.Delegate.Name,
-			Namespace: irTCPProxy.Delegate.Namespace,
-		})
-		return nil, includes, warnings, nil
+		// A tcpproxy delegate only passes on the tcpproxy of the delegated
+		// object, which is a tcpproxy include in HTTPProxy, not an include
+		// of the whole HTTPProxy.
+		return &hpv1.TCPProxy{
+			Include: &hpv1.TCPProxyInclude{
+				Name:      irTCPProxy.Delegate.Name,
+				Namespace: irTCPProxy.Delegate.Namespace,
+			},
+		}, warnings, nil
 	}
 
 	proxy := &hpv1.TCPProxy{}
-	for _, irService := range irTCPProxy.Services {
+	for index, irService := range irTCPProxy.Services {
 
-		hpService, healthcheckPolicy, lbpolicy := translateService(irService)
+		hpService, healthcheckPolicy, lbpolicy, err := translateService(irService)
+		if err != nil {
+			return nil, warnings, err
+		}
 
 		if healthcheckPolicy != nil {
-			warnings = append(warnings, "Healthcheck policy of TCPProxy service has no effect, discarding")
+			warnings = append(warnings, warning.New(warning.SeverityInfo, warning.TCPProxyHealthCheck, fmt.Sprintf(".spec.tcpproxy.services[%d].healthCheck", index),
+				"Healthcheck policy of TCPProxy service has no effect, discarding"))
 		}
 
 		if lbpolicy != nil {
@@ -275,7 +465,7 @@ func translateTCPProxy(irTCPProxy *irv1beta1.TCPProxy) (*hpv1.TCPProxy, []hpv1.I
 		}
 		proxy.Services = append(proxy.Services, hpService)
 	}
-	return proxy, includes, warnings, nil
+	return proxy, warnings, nil
 }
 
 func extractPrefixes(routes []irv1beta1.Route) []string {
@@ -315,9 +505,8 @@ func longestCommonPathPrefix(paths []string) string {
 	for index, path := range paths {
 		// Split the first '/' off, to remove the zero-length
 		// string that would otherwise be the first element.
-		if path[0] == '/' {
-			path = path[1:]
-		}
+		// A match can be empty, so don't index into it.
+		path = strings.TrimPrefix(path, "/")
 		pathElements[index] = strings.Split(path, "/")
 	}
 
@@ -330,18 +519,21 @@ func longestCommonPathPrefix(paths []string) string {
 OuterLoop:
 	for index, pathElement := range pathElements[0] {
 		for _, pathSlice := range pathElements[1:] {
-			if pathSlice[index] != pathElement {
+			// Sorting puts "/a/b/c" before "a/b", so the first path
+			// isn't always the shortest.
+			if index >= len(pathSlice) || pathSlice[index] != pathElement {
 				break OuterLoop
 			}
 		}
 		longestPrefix = append(longestPrefix, pathElement)
 	}
 
-	// If there isn't any longest prefix, just return "/"
-	if len(longestPrefix) == 0 {
+	// Empty and "/" matches share no path prefix either.
+	prefix := strings.Join(longestPrefix, "/")
+	if prefix == "" {
 		return ""
 	}
 
-	return fmt.Sprintf("/%s", strings.Join(longestPrefix, "/"))
+	return fmt.Sprintf("/%s", prefix)
 
 }
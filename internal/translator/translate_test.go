@@ -17,12 +17,16 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/ghodss/yaml"
 	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
 	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/warning"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
 )
 
 // testFixture holds test fixtures
@@ -38,23 +42,14 @@ func TestTranslateIngressRoute(t *testing.T) {
 	for name, tc := range buildFixtureSet(t) {
 		t.Run(name, func(t *testing.T) {
 
-			ir, err := k8sdecoder.DecodeIngressRoute(tc.input)
-			if err != nil {
-				t.Fatal(err)
-			}
-			hp, warnings, err := IngressRouteToHTTPProxy(ir)
+			translated, warnings, err := translateFixture(tc.input)
 			if err != nil {
 				t.Fatal(err)
 			}
-			outputYAML, err := yaml.Marshal(hp)
+			outputYAML, err := marshalFixture(translated)
 			if err != nil {
 				t.Fatal(err)
 			}
-			outputYAML = append([]byte("---\n"), outputYAML...)
-			// The Kubernetes standard header field `currentTimestamp` serializes weirdly,
-			// so filter it out.
-			// See https://github.com/projectcontour/ir2proxy/issues/8 for more explanation here.
-			outputYAML = bytes.ReplaceAll(outputYAML, []byte("  creationTimestamp: null\n"), []byte(""))
 
 			translateDiff := cmp.Diff(bytes.TrimSpace(outputYAML), bytes.TrimSpace(tc.output))
 			if translateDiff != "" {
@@ -87,6 +82,53 @@ func TestTranslateIngressRoute(t *testing.T) {
 	}
 }
 
+// marshalFixture marshals objects to YAML documents, the way they're
+// written in a fixture's output.yaml.
+func marshalFixture(objs []interface{}) ([]byte, error) {
+	var fixtureYAML []byte
+	for _, obj := range objs {
+		objYAML, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		fixtureYAML = append(fixtureYAML, "---\n"...)
+		fixtureYAML = append(fixtureYAML, objYAML...)
+	}
+	// The Kubernetes standard header field `currentTimestamp` serializes weirdly,
+	// so filter it out.
+	// See https://github.com/projectcontour/ir2proxy/issues/8 for more explanation here.
+	return bytes.ReplaceAll(fixtureYAML, []byte("  creationTimestamp: null\n"), []byte("")), nil
+}
+
+// translateFixture translates the object in a fixture's input.yaml,
+// which can be an IngressRoute, a TLSCertificateDelegation or an Ingress.
+func translateFixture(input []byte) ([]interface{}, []string, error) {
+	items, err := k8sdecoder.Decode(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(items) != 1 {
+		return nil, nil, fmt.Errorf("expected a single object, got %d", len(items))
+	}
+	switch obj := items[0].Object.(type) {
+	case *irv1beta1.IngressRoute:
+		hp, warnings, err := IngressRouteToHTTPProxy(obj)
+		return []interface{}{hp}, warning.Strings(warnings), err
+	case *irv1beta1.TLSCertificateDelegation:
+		tcd, warnings := TLSCertificateDelegationToV1(obj)
+		return []interface{}{tcd}, warning.Strings(warnings), nil
+	case *netv1beta1.Ingress:
+		proxies, warnings, err := IngressToHTTPProxies(obj)
+		var translated []interface{}
+		for _, hp := range proxies {
+			translated = append(translated, hp)
+		}
+		return translated, warning.Strings(warnings), err
+	default:
+		return nil, nil, fmt.Errorf("can't translate a %s", items[0].GroupVersionKind)
+	}
+}
+
 func TestTranslateIngressRouteErrors(t *testing.T) {
 
 	tests := map[string]struct {
@@ -149,7 +191,7 @@ spec:
      - name: s1
        port: 80
 `),
-			want: []string{"invalid IngressRoute: tcpproxy must be in a root IngressRoute"},
+			want: []string{"invalid IngressRoute: tcpproxy must be in a root IngressRoute, or one that a tcpproxy delegates to"},
 		},
 		"tcpproxy IR, delegate and services set": {
 			input: []byte(`
@@ -174,6 +216,49 @@ spec:
 `),
 			want: []string{"invalid IngressRoute: Delegate and Services can not both be set"},
 		},
+		"upstream validation without subjectName": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: validation-nosubject
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: "validation-test.domain.com"
+  routes:
+    - match: /
+      services:
+        - name: s1
+          port: 443
+          validation:
+            caSecret: my-certificate-authority
+`),
+			want: []string{"invalid IngressRoute: validation on service s1 must have both caSecret and subjectName set"},
+		},
+		"tcpproxy upstream validation without caSecret": {
+			input: []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: validation-noca
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: "tcpproxy-test.domain.com"
+    tls:
+      secretName: "secret"
+  tcpproxy:
+    services:
+      - name: s1
+        port: 443
+        validation:
+          subjectName: backend.example.com
+`),
+			want: []string{"invalid IngressRoute: validation on service s1 must have both caSecret and subjectName set"},
+		},
 	}
 
 	for name, tc := range tests {
@@ -183,27 +268,161 @@ spec:
 				t.Fatal(err)
 			}
 			_, _, err = IngressRouteToHTTPProxy(ir)
+			if err == nil {
+				t.Fatalf("Expected translation error not encountered:\n%v", tc.want)
+			}
+			// Can't translate the IngressRoute at all
+			// errors.txt should have the error message.
+			errorDiff := cmp.Diff([]string{err.Error()}, tc.want)
+			if errorDiff != "" {
+				t.Fatalf("Expected translation error not encountered:\n%v", errorDiff)
+			}
+		})
+	}
+
+}
+
+func TestTranslateIngressRouteWarnings(t *testing.T) {
+
+	// The structured fields of the warnings from some fixtures. The messages
+	// are checked against errors.txt by TestTranslateIngressRoute.
+	type structured struct {
+		Code     warning.Code
+		Severity warning.Severity
+		Object   warning.Object
+		Path     string
+		DocsURL  string
+	}
+
+	tests := map[string][]structured{
+		"lb-diff-strategy": {{
+			Code:     warning.LBConflict,
+			Severity: warning.SeverityWarning,
+			Object:   warning.Object{Kind: "IngressRoute", Namespace: "default", Name: "lb-strategy"},
+			Path:     ".spec.routes[0].services[1].strategy",
+		}},
+		"retry-policy-invalid": {{
+			Code:     warning.RetryTimeoutInvalid,
+			Severity: warning.SeverityInfo,
+			Object:   warning.Object{Kind: "IngressRoute", Namespace: "default", Name: "retry-policy-invalid"},
+			Path:     ".spec.routes[0].retryPolicy.perTryTimeout",
+		}, {
+			Code:     warning.RetryTimeoutInvalid,
+			Severity: warning.SeverityInfo,
+			Object:   warning.Object{Kind: "IngressRoute", Namespace: "default", Name: "retry-policy-invalid"},
+			Path:     ".spec.routes[1].retryPolicy.perTryTimeout",
+		}},
+		"nonroot_ambiguous_prefix": {{
+			Code:     warning.IncludePrefixSingleMatch,
+			Severity: warning.SeverityWarning,
+			Object:   warning.Object{Kind: "IngressRoute", Namespace: "default", Name: "nonroot-ambiguous-match"},
+			Path:     ".spec.routes[0].match",
+			DocsURL:  conditionsDocsURL,
+		}},
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			input, err := ioutil.ReadFile(fmt.Sprintf("testdata/%s/input.yaml", name))
 			if err != nil {
-				// Can't translate the IngressRoute at all
-				// errors.txt should have the error message.
-				errorDiff := cmp.Diff([]string{err.Error()}, tc.want)
-				if errorDiff != "" {
-					t.Fatalf("Expected translation error not encountered:\n%v", errorDiff)
-				}
+				t.Fatal(err)
+			}
+			ir := &irv1beta1.IngressRoute{}
+			if err := yaml.Unmarshal(input, ir); err != nil {
+				t.Fatal(err)
+			}
+
+			_, warnings, err := IngressRouteToHTTPProxy(ir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []structured
+			for _, w := range warnings {
+				got = append(got, structured{Code: w.Code, Severity: w.Severity, Object: w.Object, Path: w.Path, DocsURL: w.DocsURL})
+			}
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Fatalf("Warnings mismatch:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTranslateHealthCheckFields(t *testing.T) {
+
+	// Every field of the IngressRoute HealthCheck, and the HTTPHealthCheckPolicy
+	// field it's translated to.
+	// If a field is added to the IngressRoute HealthCheck, this test will fail
+	// until it's added here, so that it can't be dropped silently.
+	tests := map[string]string{
+		"Path":                    "Path",
+		"Host":                    "Host",
+		"IntervalSeconds":         "IntervalSeconds",
+		"TimeoutSeconds":          "TimeoutSeconds",
+		"UnhealthyThresholdCount": "UnhealthyThresholdCount",
+		"HealthyThresholdCount":   "HealthyThresholdCount",
+	}
+
+	healthCheckType := reflect.TypeOf(irv1beta1.HealthCheck{})
+	for i := 0; i < healthCheckType.NumField(); i++ {
+		field := healthCheckType.Field(i)
+		t.Run(field.Name, func(t *testing.T) {
+			hpField, ok := tests[field.Name]
+			if !ok {
+				t.Fatalf("IngressRoute HealthCheck field %s is not covered by this test, is it translated?", field.Name)
+			}
+
+			var healthCheck irv1beta1.HealthCheck
+			irValue := reflect.ValueOf(&healthCheck).Elem().Field(i)
+			setNonZero(t, irValue)
+
+			_, healthCheckPolicy, _, err := translateService(irv1beta1.Service{
+				Name:        "s1",
+				Port:        80,
+				HealthCheck: &healthCheck,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if healthCheckPolicy == nil {
+				t.Fatal("expected a healthcheck policy, got nil")
+			}
+
+			hpValue := reflect.ValueOf(healthCheckPolicy).Elem().FieldByName(hpField)
+			if !hpValue.IsValid() {
+				t.Fatalf("HTTPHealthCheckPolicy has no field %s", hpField)
+			}
+			if !reflect.DeepEqual(hpValue.Interface(), irValue.Interface()) {
+				t.Fatalf("field %s was not translated to %s: expected '%v', got '%v'", field.Name, hpField, irValue.Interface(), hpValue.Interface())
 			}
 		})
 	}
+}
 
+// setNonZero sets value to a non-zero value of its kind.
+func setNonZero(t *testing.T, value reflect.Value) {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString("test-value")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(42)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(42)
+	case reflect.Bool:
+		value.SetBool(true)
+	default:
+		t.Fatalf("don't know how to set a non-zero %s", value.Kind())
+	}
 }
 
-func buildFixtureSet(t *testing.T) map[string]testFixture {
+func buildFixtureSet(t testing.TB) map[string]testFixture {
 	testdataFiles, err := ioutil.ReadDir("testdata")
 	if err != nil {
 		panic(err)
 	}
 	fixtures := make(map[string]testFixture)
 	for _, fileinfo := range testdataFiles {
-		if !fileinfo.IsDir() {
+		// testdata/fuzz holds the corpus of the fuzz tests.
+		if !fileinfo.IsDir() || fileinfo.Name() == "fuzz" {
 			continue
 		}
 
@@ -295,6 +514,18 @@ func TestLongestCommonPathPrefix(t *testing.T) {
 			input: []string{"/long/path/first", "/long"},
 			want:  "/long",
 		},
+		"empty match": {
+			input: []string{"", "/foo"},
+			want:  "",
+		},
+		"two empty matches": {
+			input: []string{"", ""},
+			want:  "",
+		},
+		"sorted first is longest": {
+			input: []string{"/foo/bar/baz", "foo/bar"},
+			want:  "/foo/bar",
+		},
 	}
 
 	for name, tc := range tests {
@@ -15,17 +15,71 @@
 package validate
 
 import (
+	"strings"
+
 	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
+	"github.com/projectcontour/ir2proxy/internal/warning"
 )
 
 // CheckIngressRoute checks an IngressRoute and returns a slice of warnings if any problems are found.
-func CheckIngressRoute(ir *irv1beta1.IngressRoute) []string {
+// The warnings have SeverityError, since an IngressRoute with problems can't be translated.
+func CheckIngressRoute(ir *irv1beta1.IngressRoute) []warning.Warning {
 
-	var warnings []string
+	var warnings []warning.Warning
 
 	if ir.ObjectMeta.Name == "" {
-		warnings = append(warnings, "Name cannot be empty")
+		warnings = append(warnings, warning.New(warning.SeverityError, warning.NameEmpty, ".metadata.name", "Name cannot be empty"))
+	}
+
+	return warning.WithObject(warnings, warning.Object{Kind: "IngressRoute", Namespace: ir.Namespace, Name: ir.Name})
+}
+
+// CheckTLSCertificateDelegations checks that every IngressRoute that uses a TLS secret
+// from another namespace, with a secretName of the form `namespace/name`, has that
+// secret delegated to it by one of the TLSCertificateDelegations.
+// It returns a slice of warnings for any secret that isn't delegated.
+func CheckTLSCertificateDelegations(irs []*irv1beta1.IngressRoute, tcds []*irv1beta1.TLSCertificateDelegation) []warning.Warning {
+
+	var warnings []warning.Warning
+
+	for _, ir := range irs {
+		if ir.Spec.VirtualHost == nil || ir.Spec.VirtualHost.TLS == nil {
+			continue
+		}
+		secretName := ir.Spec.VirtualHost.TLS.SecretName
+		parts := strings.SplitN(secretName, "/", 2)
+		if len(parts) != 2 || parts[0] == ir.ObjectMeta.Namespace {
+			// Secrets in the same namespace don't need delegating.
+			continue
+		}
+		if !delegated(tcds, parts[0], parts[1], ir.ObjectMeta.Namespace) {
+			w := warning.New(warning.SeverityWarning, warning.SecretNotDelegated, ".spec.virtualhost.tls.secretName",
+				"Secret %s used by IngressRoute %s/%s is not delegated to namespace %s by any TLSCertificateDelegation in the input. Please check the delegation exists.", secretName, ir.ObjectMeta.Namespace, ir.ObjectMeta.Name, ir.ObjectMeta.Namespace)
+			w.Object = warning.Object{Kind: "IngressRoute", Namespace: ir.Namespace, Name: ir.Name}
+			warnings = append(warnings, w)
+		}
 	}
 
 	return warnings
 }
+
+// delegated returns true if any of tcds delegates the secret namespace/name
+// to targetNamespace.
+func delegated(tcds []*irv1beta1.TLSCertificateDelegation, namespace, name, targetNamespace string) bool {
+	for _, tcd := range tcds {
+		if tcd.ObjectMeta.Namespace != namespace {
+			continue
+		}
+		for _, delegation := range tcd.Spec.Delegations {
+			if delegation.SecretName != name {
+				continue
+			}
+			for _, target := range delegation.TargetNamespaces {
+				if target == targetNamespace || target == "*" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -20,7 +20,9 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	irv1beta1 "github.com/projectcontour/contour/apis/contour/v1beta1"
 	"github.com/projectcontour/ir2proxy/internal/k8sdecoder"
+	"github.com/projectcontour/ir2proxy/internal/warning"
 )
 
 func TestCheckIngressRoute(t *testing.T) {
@@ -28,7 +30,148 @@ func TestCheckIngressRoute(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			ir, _ := k8sdecoder.DecodeIngressRoute(tc.input)
 			warnings := CheckIngressRoute(ir)
-			diff := cmp.Diff(warnings, tc.want)
+			diff := cmp.Diff(warning.Strings(warnings), tc.want)
+			if diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestCheckTLSCertificateDelegations(t *testing.T) {
+
+	ir := []byte(`
+---
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: tls
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: tls.bar.com
+    tls:
+      secretName: certificates/wildcard
+  routes:
+    - match: /
+      services:
+        - name: s1
+          port: 80
+`)
+
+	tests := map[string]struct {
+		irs  [][]byte
+		tcds [][]byte
+		want []string
+	}{
+		"delegated to namespace": {
+			irs: [][]byte{ir},
+			tcds: [][]byte{[]byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: TLSCertificateDelegation
+metadata:
+  name: delegation
+  namespace: certificates
+spec:
+  delegations:
+    - secretName: wildcard
+      targetNamespaces:
+        - default
+`)},
+			want: nil,
+		},
+		"delegated to all namespaces": {
+			irs: [][]byte{ir},
+			tcds: [][]byte{[]byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: TLSCertificateDelegation
+metadata:
+  name: delegation
+  namespace: certificates
+spec:
+  delegations:
+    - secretName: wildcard
+      targetNamespaces:
+        - "*"
+`)},
+			want: nil,
+		},
+		"delegated to another namespace": {
+			irs: [][]byte{ir},
+			tcds: [][]byte{[]byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: TLSCertificateDelegation
+metadata:
+  name: delegation
+  namespace: certificates
+spec:
+  delegations:
+    - secretName: wildcard
+      targetNamespaces:
+        - team-a
+`)},
+			want: []string{"Secret certificates/wildcard used by IngressRoute default/tls is not delegated to namespace default by any TLSCertificateDelegation in the input. Please check the delegation exists."},
+		},
+		"delegation in the wrong namespace": {
+			irs: [][]byte{ir},
+			tcds: [][]byte{[]byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: TLSCertificateDelegation
+metadata:
+  name: delegation
+  namespace: default
+spec:
+  delegations:
+    - secretName: wildcard
+      targetNamespaces:
+        - default
+`)},
+			want: []string{"Secret certificates/wildcard used by IngressRoute default/tls is not delegated to namespace default by any TLSCertificateDelegation in the input. Please check the delegation exists."},
+		},
+		"no delegations": {
+			irs:  [][]byte{ir},
+			want: []string{"Secret certificates/wildcard used by IngressRoute default/tls is not delegated to namespace default by any TLSCertificateDelegation in the input. Please check the delegation exists."},
+		},
+		"secret in the same namespace": {
+			irs: [][]byte{[]byte(`
+apiVersion: contour.heptio.com/v1beta1
+kind: IngressRoute
+metadata:
+  name: tls
+  namespace: default
+spec:
+  virtualhost:
+    fqdn: tls.bar.com
+    tls:
+      secretName: secret
+`)},
+			want: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var irs []*irv1beta1.IngressRoute
+			for _, input := range tc.irs {
+				ir, err := k8sdecoder.DecodeIngressRoute(input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				irs = append(irs, ir)
+			}
+			var tcds []*irv1beta1.TLSCertificateDelegation
+			for _, input := range tc.tcds {
+				items, err := k8sdecoder.Decode(input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tcd, ok := items[0].Object.(*irv1beta1.TLSCertificateDelegation)
+				if !ok {
+					t.Fatalf("want a TLSCertificateDelegation, got: %T", items[0].Object)
+				}
+				tcds = append(tcds, tcd)
+			}
+			diff := cmp.Diff(warning.Strings(CheckTLSCertificateDelegations(irs, tcds)), tc.want)
 			if diff != "" {
 				t.Fatal(diff)
 			}
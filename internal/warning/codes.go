@@ -0,0 +1,135 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warning
+
+// Code identifies a kind of Warning. Codes are part of ir2proxy's interface,
+// so once released, a code must keep its meaning, and must not be reused.
+type Code string
+
+// Codes for translating IngressRoutes to HTTPProxies.
+const (
+	IncludePrefixSingleMatch Code = "IR2P-INCLUDE-PREFIX-SINGLE-MATCH"
+	IncludePrefixGuess       Code = "IR2P-INCLUDE-PREFIX-GUESS"
+	IncludePrefixInexact     Code = "IR2P-INCLUDE-PREFIX-INEXACT"
+	IncludePrefixMultiple    Code = "IR2P-INCLUDE-PREFIX-MULTIPLE"
+	LBConflict               Code = "IR2P-LB-CONFLICT"
+	HealthCheckConflict      Code = "IR2P-HEALTHCHECK-CONFLICT"
+	TCPProxyHealthCheck      Code = "IR2P-TCPPROXY-HEALTHCHECK"
+	RetryTimeoutInvalid      Code = "IR2P-RETRY-TIMEOUT-INVALID"
+	DelegateWebsockets       Code = "IR2P-DELEGATE-WEBSOCKETS"
+	DelegatePermitInsecure   Code = "IR2P-DELEGATE-PERMIT-INSECURE"
+	FieldDropped             Code = "IR2P-FIELD-DROPPED"
+	TLSDelegationNoTargets   Code = "IR2P-TLS-DELEGATION-NO-TARGETS"
+)
+
+// Codes for validating IngressRoutes.
+const (
+	NameEmpty             Code = "IR2P-NAME-EMPTY"
+	SecretNotDelegated    Code = "IR2P-SECRET-NOT-DELEGATED"
+	DelegationCycle       Code = "IR2P-DELEGATION-CYCLE"
+	DelegationDangling    Code = "IR2P-DELEGATION-DANGLING"
+	DelegationUnreachable Code = "IR2P-DELEGATION-UNREACHABLE"
+	DelegationConflict    Code = "IR2P-DELEGATION-CONFLICT"
+)
+
+// Codes for translating Ingresses to HTTPProxies.
+const (
+	IngressWildcardHost          Code = "IR2P-INGRESS-WILDCARD-HOST"
+	IngressNoHost                Code = "IR2P-INGRESS-NO-HOST"
+	IngressAllowHTTP             Code = "IR2P-INGRESS-ALLOW-HTTP"
+	IngressAllowHTTPNoTLS        Code = "IR2P-INGRESS-ALLOW-HTTP-NO-TLS"
+	IngressForceSSLRedirectNoTLS Code = "IR2P-INGRESS-FORCE-SSL-REDIRECT-NO-TLS"
+	IngressTimeoutInvalid        Code = "IR2P-INGRESS-TIMEOUT-INVALID"
+	IngressRetryOnMissing        Code = "IR2P-INGRESS-RETRY-ON-MISSING"
+	IngressRetryOn               Code = "IR2P-INGRESS-RETRY-ON"
+	IngressNumRetriesInvalid     Code = "IR2P-INGRESS-NUM-RETRIES-INVALID"
+	IngressPerTryTimeoutInvalid  Code = "IR2P-INGRESS-PER-TRY-TIMEOUT-INVALID"
+)
+
+// Codes for translating IngressRoutes to Gateway API.
+const (
+	GatewayTLSMinimumVersion      Code = "IR2P-GATEWAY-TLS-MINIMUM-VERSION"
+	GatewayTLSPassthrough         Code = "IR2P-GATEWAY-TLS-PASSTHROUGH"
+	GatewayTimeoutInvalid         Code = "IR2P-GATEWAY-TIMEOUT-INVALID"
+	GatewayRetryPolicy            Code = "IR2P-GATEWAY-RETRY-POLICY"
+	GatewayWebsockets             Code = "IR2P-GATEWAY-WEBSOCKETS"
+	GatewayDelegateWebsockets     Code = "IR2P-GATEWAY-DELEGATE-WEBSOCKETS"
+	GatewayDelegatePermitInsecure Code = "IR2P-GATEWAY-DELEGATE-PERMIT-INSECURE"
+	GatewayDelegateOutsidePrefix  Code = "IR2P-GATEWAY-DELEGATE-OUTSIDE-PREFIX"
+	GatewayTCPProxyDelegate       Code = "IR2P-GATEWAY-TCPPROXY-DELEGATE"
+	GatewayTCPProxyNoTLS          Code = "IR2P-GATEWAY-TCPPROXY-NO-TLS"
+	GatewayLBStrategy             Code = "IR2P-GATEWAY-LB-STRATEGY"
+	GatewayHealthCheck            Code = "IR2P-GATEWAY-HEALTHCHECK"
+	GatewayUpstreamValidation     Code = "IR2P-GATEWAY-UPSTREAM-VALIDATION"
+	GatewayNoParent               Code = "IR2P-GATEWAY-NO-PARENT"
+	GatewayPathPrefix             Code = "IR2P-GATEWAY-PATH-PREFIX"
+)
+
+// Codes for translating HTTPProxies back to IngressRoutes.
+const (
+	ReverseIncludePrefixGuess Code = "IR2P-REVERSE-INCLUDE-PREFIX-GUESS"
+)
+
+// knownCodes is every Code above.
+var knownCodes = map[Code]bool{
+	IncludePrefixSingleMatch:      true,
+	IncludePrefixGuess:            true,
+	IncludePrefixInexact:          true,
+	IncludePrefixMultiple:         true,
+	LBConflict:                    true,
+	HealthCheckConflict:           true,
+	TCPProxyHealthCheck:           true,
+	RetryTimeoutInvalid:           true,
+	DelegateWebsockets:            true,
+	DelegatePermitInsecure:        true,
+	FieldDropped:                  true,
+	TLSDelegationNoTargets:        true,
+	NameEmpty:                     true,
+	SecretNotDelegated:            true,
+	DelegationCycle:               true,
+	DelegationDangling:            true,
+	DelegationUnreachable:         true,
+	DelegationConflict:            true,
+	IngressWildcardHost:           true,
+	IngressNoHost:                 true,
+	IngressAllowHTTP:              true,
+	IngressAllowHTTPNoTLS:         true,
+	IngressForceSSLRedirectNoTLS:  true,
+	IngressTimeoutInvalid:         true,
+	IngressRetryOnMissing:         true,
+	IngressRetryOn:                true,
+	IngressNumRetriesInvalid:      true,
+	IngressPerTryTimeoutInvalid:   true,
+	GatewayTLSMinimumVersion:      true,
+	GatewayTLSPassthrough:         true,
+	GatewayTimeoutInvalid:         true,
+	GatewayRetryPolicy:            true,
+	GatewayWebsockets:             true,
+	GatewayDelegateWebsockets:     true,
+	GatewayDelegatePermitInsecure: true,
+	GatewayDelegateOutsidePrefix:  true,
+	GatewayTCPProxyDelegate:       true,
+	GatewayTCPProxyNoTLS:          true,
+	GatewayLBStrategy:             true,
+	GatewayHealthCheck:            true,
+	GatewayUpstreamValidation:     true,
+	GatewayNoParent:               true,
+	GatewayPathPrefix:             true,
+	ReverseIncludePrefixGuess:     true,
+}
+
+// Known returns true if the code is one that ir2proxy reports.
+func (c Code) Known() bool {
+	return knownCodes[c]
+}
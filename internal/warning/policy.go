@@ -0,0 +1,131 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warning
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IgnoreAnnotation is the annotation on a source object that lists the codes
+// of the warnings to ignore for it, separated by commas.
+const IgnoreAnnotation = "ir2proxy.projectcontour.io/ignore"
+
+// Policy decides which warnings are reported, and how severe they are.
+// The zero Policy reports every warning unchanged.
+type Policy struct {
+	// Ignore is the codes of warnings to drop.
+	Ignore map[Code]bool
+	// ObjectIgnore is the codes of warnings to drop for particular objects.
+	ObjectIgnore map[Object]map[Code]bool
+	// Severities overrides the severity of warnings with these codes.
+	Severities map[Code]Severity
+	// WarningsAsErrors raises warnings with SeverityWarning to
+	// SeverityError, after Severities are applied.
+	WarningsAsErrors bool
+}
+
+// IgnoreAnnotated adds the codes in the IgnoreAnnotation of object to
+// ObjectIgnore. Known codes are added even if there's an error.
+func (p *Policy) IgnoreAnnotated(object Object, annotations map[string]string) error {
+	value, ok := annotations[IgnoreAnnotation]
+	if !ok {
+		return nil
+	}
+	codes, err := ParseCodes(value)
+	for _, code := range codes {
+		if p.ObjectIgnore == nil {
+			p.ObjectIgnore = make(map[Object]map[Code]bool)
+		}
+		if p.ObjectIgnore[object] == nil {
+			p.ObjectIgnore[object] = make(map[Code]bool)
+		}
+		p.ObjectIgnore[object][code] = true
+	}
+	if err != nil {
+		return fmt.Errorf("invalid annotation %s on %s: %s", IgnoreAnnotation, object, err)
+	}
+	return nil
+}
+
+// Apply returns the warnings that aren't ignored, with their severities
+// changed by the Policy.
+func (p Policy) Apply(warnings []Warning) []Warning {
+	var applied []Warning
+	for _, w := range warnings {
+		if p.Ignore[w.Code] || p.ObjectIgnore[w.Object][w.Code] {
+			continue
+		}
+		if severity, ok := p.Severities[w.Code]; ok {
+			w.Severity = severity
+		}
+		if p.WarningsAsErrors && w.Severity == SeverityWarning {
+			w.Severity = SeverityError
+		}
+		applied = append(applied, w)
+	}
+	return applied
+}
+
+// HasErrors returns true if any of the warnings has SeverityError.
+func HasErrors(warnings []Warning) bool {
+	for _, w := range warnings {
+		if w.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCodes parses a list of codes separated by commas. The codes that are
+// known are returned, even if there's an error.
+func ParseCodes(value string) ([]Code, error) {
+	var codes []Code
+	var unknown []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		code := Code(strings.ToUpper(field))
+		if !code.Known() {
+			unknown = append(unknown, field)
+			continue
+		}
+		codes = append(codes, code)
+	}
+	if len(unknown) > 0 {
+		return codes, fmt.Errorf("unknown warning code %s", strings.Join(unknown, ", "))
+	}
+	return codes, nil
+}
+
+// ParseSeverity parses a severity override, like IR2P-LB-CONFLICT=error.
+func ParseSeverity(value string) (Code, Severity, error) {
+	parts := strings.Split(value, "=")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid severity %q, must be CODE=SEVERITY", value)
+	}
+	code := Code(strings.ToUpper(strings.TrimSpace(parts[0])))
+	if !code.Known() {
+		return "", "", fmt.Errorf("invalid severity %q, unknown warning code %s", value, parts[0])
+	}
+	severity := Severity(strings.ToLower(strings.TrimSpace(parts[1])))
+	switch severity {
+	case SeverityInfo, SeverityWarning, SeverityError:
+	default:
+		return "", "", fmt.Errorf("invalid severity %q, must be info, warning or error", value)
+	}
+	return code, severity, nil
+}
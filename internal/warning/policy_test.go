@@ -0,0 +1,189 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warning
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApply(t *testing.T) {
+
+	root := Object{Kind: "IngressRoute", Namespace: "default", Name: "root"}
+	blog := Object{Kind: "IngressRoute", Namespace: "blog", Name: "blog"}
+	warnings := []Warning{
+		{Code: LBConflict, Severity: SeverityWarning, Object: root},
+		{Code: HealthCheckConflict, Severity: SeverityWarning, Object: root},
+		{Code: RetryTimeoutInvalid, Severity: SeverityInfo, Object: root},
+		{Code: IncludePrefixGuess, Severity: SeverityWarning, Object: root},
+		{Code: IncludePrefixGuess, Severity: SeverityWarning, Object: blog},
+	}
+
+	tests := map[string]struct {
+		policy Policy
+		want   []Warning
+	}{
+		"zero policy": {
+			want: warnings,
+		},
+		"ignore": {
+			policy: Policy{Ignore: map[Code]bool{IncludePrefixGuess: true}},
+			want:   warnings[:3],
+		},
+		"ignore for an object": {
+			policy: Policy{ObjectIgnore: map[Object]map[Code]bool{blog: {IncludePrefixGuess: true}}},
+			want:   warnings[:4],
+		},
+		"severities": {
+			policy: Policy{Severities: map[Code]Severity{HealthCheckConflict: SeverityError, LBConflict: SeverityInfo}},
+			want: []Warning{
+				{Code: LBConflict, Severity: SeverityInfo, Object: root},
+				{Code: HealthCheckConflict, Severity: SeverityError, Object: root},
+				warnings[2],
+				warnings[3],
+				warnings[4],
+			},
+		},
+		"warnings as errors after severities": {
+			policy: Policy{
+				Ignore:           map[Code]bool{IncludePrefixGuess: true},
+				Severities:       map[Code]Severity{LBConflict: SeverityInfo},
+				WarningsAsErrors: true,
+			},
+			want: []Warning{
+				{Code: LBConflict, Severity: SeverityInfo, Object: root},
+				{Code: HealthCheckConflict, Severity: SeverityError, Object: root},
+				warnings[2],
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tc.policy.Apply(warnings)
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatalf("Warnings mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestIgnoreAnnotated(t *testing.T) {
+
+	root := Object{Kind: "IngressRoute", Namespace: "default", Name: "root"}
+
+	var policy Policy
+	if err := policy.IgnoreAnnotated(root, map[string]string{"other": "IR2P-LB-CONFLICT"}); err != nil {
+		t.Fatal(err)
+	}
+	if policy.ObjectIgnore != nil {
+		t.Fatalf("expected no codes to be ignored, got %v", policy.ObjectIgnore)
+	}
+
+	err := policy.IgnoreAnnotated(root, map[string]string{IgnoreAnnotation: "ir2p-lb-conflict, IR2P-UNKNOWN"})
+	wantErr := "invalid annotation ir2proxy.projectcontour.io/ignore on IngressRoute default/root: unknown warning code IR2P-UNKNOWN"
+	if err == nil || err.Error() != wantErr {
+		t.Fatalf("expected error %q, got %v", wantErr, err)
+	}
+	want := map[Object]map[Code]bool{root: {LBConflict: true}}
+	if diff := cmp.Diff(policy.ObjectIgnore, want); diff != "" {
+		t.Fatalf("ObjectIgnore mismatch:\n%v", diff)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+
+	tests := map[string]struct {
+		input        string
+		wantCode     Code
+		wantSeverity Severity
+		wantErr      string
+	}{
+		"valid": {
+			input:        "ir2p-lb-conflict=Error",
+			wantCode:     LBConflict,
+			wantSeverity: SeverityError,
+		},
+		"no severity": {
+			input:   "IR2P-LB-CONFLICT",
+			wantErr: `invalid severity "IR2P-LB-CONFLICT", must be CODE=SEVERITY`,
+		},
+		"unknown code": {
+			input:   "IR2P-UNKNOWN=error",
+			wantErr: `invalid severity "IR2P-UNKNOWN=error", unknown warning code IR2P-UNKNOWN`,
+		},
+		"unknown severity": {
+			input:   "IR2P-LB-CONFLICT=fatal",
+			wantErr: `invalid severity "IR2P-LB-CONFLICT=fatal", must be info, warning or error`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			code, severity, err := ParseSeverity(tc.input)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code != tc.wantCode || severity != tc.wantSeverity {
+				t.Fatalf("expected %s=%s, got %s=%s", tc.wantCode, tc.wantSeverity, code, severity)
+			}
+		})
+	}
+}
+
+func TestKnownCodes(t *testing.T) {
+
+	// Every Code constant must be in knownCodes, or it can't be ignored.
+	file, err := parser.ParseFile(token.NewFileSet(), "codes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok || spec.Type == nil || len(spec.Values) != 1 {
+			return true
+		}
+		if ident, ok := spec.Type.(*ast.Ident); !ok || ident.Name != "Code" {
+			return true
+		}
+		literal, ok := spec.Values[0].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		value, err := strconv.Unquote(literal.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Code(value).Known() {
+			t.Errorf("code %s is not in knownCodes", value)
+		}
+		count++
+		return true
+	})
+	if count != len(knownCodes) {
+		t.Errorf("expected %d Code constants, found %d", len(knownCodes), count)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package warning describes the problems found while translating objects
+package warning
+
+import (
+	"fmt"
+)
+
+// Severity is how much a Warning matters.
+type Severity string
+
+const (
+	// SeverityInfo is something that doesn't change how traffic is routed,
+	// like a field Contour already ignored.
+	SeverityInfo Severity = "info"
+	// SeverityWarning is something that may change how traffic is routed,
+	// and should be checked.
+	SeverityWarning Severity = "warning"
+	// SeverityError is an object that can't be translated.
+	SeverityError Severity = "error"
+)
+
+// Object identifies the object that a Warning is about.
+type Object struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (o Object) String() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+// Warning is a problem found while translating an object.
+type Warning struct {
+	// Code identifies the kind of problem, and doesn't change between releases.
+	Code Code `json:"code"`
+	// Severity is how much the problem matters.
+	Severity Severity `json:"severity"`
+	// Object is the object the problem is in. It's empty if the problem
+	// isn't about a single object.
+	Object Object `json:"object"`
+	// Path is the path of the field the problem is in, like
+	// `.spec.routes[0].services[1].strategy`. It's empty if the problem
+	// is with the whole object.
+	Path string `json:"path,omitempty"`
+	// Message describes the problem, and what to do about it.
+	Message string `json:"message"`
+	// DocsURL links to documentation about the problem, if there is any.
+	DocsURL string `json:"docsURL,omitempty"`
+}
+
+// New returns a Warning, with the message formatted from format and args.
+func New(severity Severity, code Code, path string, format string, args ...interface{}) Warning {
+	return Warning{
+		Code:     code,
+		Severity: severity,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// WithDocs returns a copy of the Warning that links to url.
+func (w Warning) WithDocs(url string) Warning {
+	w.DocsURL = url
+	return w
+}
+
+// String returns the message, followed by the docs link.
+func (w Warning) String() string {
+	if w.DocsURL == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("%s See %s", w.Message, w.DocsURL)
+}
+
+// WithObject sets the Object of each Warning that doesn't have one, and
+// returns them.
+func WithObject(warnings []Warning, object Object) []Warning {
+	for index := range warnings {
+		if warnings[index].Object == (Object{}) {
+			warnings[index].Object = object
+		}
+	}
+	return warnings
+}
+
+// Strings returns the String of each Warning.
+func Strings(warnings []Warning) []string {
+	var strings []string
+	for _, warning := range warnings {
+		strings = append(strings, warning.String())
+	}
+	return strings
+}
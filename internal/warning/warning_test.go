@@ -0,0 +1,93 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package warning
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestString(t *testing.T) {
+
+	tests := map[string]struct {
+		warning Warning
+		want    string
+	}{
+		"message only": {
+			warning: New(SeverityWarning, LBConflict, ".spec.routes[0].services[1].strategy", "Strategy %s could not be applied.", "Random"),
+			want:    "Strategy Random could not be applied.",
+		},
+		"with docs": {
+			warning: New(SeverityWarning, IncludePrefixGuess, "", "Please check the prefix.").WithDocs("https://projectcontour.io/docs/"),
+			want:    "Please check the prefix. See https://projectcontour.io/docs/",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.warning.String(), tc.want); diff != "" {
+				t.Fatalf("String mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestObjectString(t *testing.T) {
+
+	tests := map[string]struct {
+		object Object
+		want   string
+	}{
+		"namespaced": {
+			object: Object{Kind: "IngressRoute", Namespace: "default", Name: "root"},
+			want:   "IngressRoute default/root",
+		},
+		"no namespace": {
+			object: Object{Kind: "IngressRoute", Name: "root"},
+			want:   "IngressRoute root",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.object.String(), tc.want); diff != "" {
+				t.Fatalf("String mismatch:\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestWithObject(t *testing.T) {
+
+	other := Object{Kind: "IngressRoute", Namespace: "blog", Name: "blog"}
+	warnings := []Warning{
+		New(SeverityWarning, LBConflict, "", "first"),
+		{Code: DelegationDangling, Severity: SeverityWarning, Object: other, Message: "second"},
+	}
+
+	root := Object{Kind: "IngressRoute", Namespace: "default", Name: "root"}
+	got := WithObject(warnings, root)
+
+	want := []Warning{
+		{Code: LBConflict, Severity: SeverityWarning, Object: root, Message: "first"},
+		{Code: DelegationDangling, Severity: SeverityWarning, Object: other, Message: "second"},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Fatalf("Warnings mismatch:\n%v", diff)
+	}
+	if diff := cmp.Diff(Strings(got), []string{"first", "second"}); diff != "" {
+		t.Fatalf("Strings mismatch:\n%v", diff)
+	}
+}